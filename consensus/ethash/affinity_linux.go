@@ -0,0 +1,43 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build linux
+
+package ethash
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// affinitySupported reports whether mining threads can be pinned to CPUs.
+const affinitySupported = true
+
+// pinThread locks the calling goroutine to its OS thread and restricts that
+// thread to the given CPU. The goroutine must exit without unlocking, so that
+// the runtime discards the pinned thread instead of reusing it elsewhere.
+func pinThread(cpu int) error {
+	runtime.LockOSThread()
+
+	var set unix.CPUSet
+	set.Set(cpu)
+	if err := unix.SchedSetaffinity(0, &set); err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	return nil
+}
@@ -18,6 +18,7 @@ package ethash
 
 import (
 	"encoding/binary"
+	"fmt"
 	"hash"
 	"math/big"
 	"reflect"
@@ -35,19 +36,65 @@ import (
 )
 
 const (
-	datasetInitBytes   = 1 << 30 // Bytes in dataset at genesis
-	datasetGrowthBytes = 1 << 23 // Dataset growth per epoch
-	cacheInitBytes     = 1 << 24 // Bytes in cache at genesis
-	cacheGrowthBytes   = 1 << 17 // Cache growth per epoch
-	epochLength        = 30000   // Blocks per epoch
-	mixBytes           = 128     // Width of mix
-	hashBytes          = 64      // Hash length in bytes
-	hashWords          = 16      // Number of 32 bit ints in a hash
-	datasetParents     = 256     // Number of parents of each dataset element
-	cacheRounds        = 3       // Number of rounds in cache production
-	loopAccesses       = 64      // Number of accesses in hashimoto loop
+	epochLength    = 30000 // Blocks per epoch
+	mixBytes       = 128   // Width of mix
+	hashBytes      = 64    // Hash length in bytes
+	hashWords      = 16    // Number of 32 bit ints in a hash
+	datasetParents = 256   // Number of parents of each dataset element
+	cacheRounds    = 3     // Number of rounds in cache production
+	loopAccesses   = 64    // Number of accesses in hashimoto loop
 )
 
+// MemoryParams defines the sizing of the ethash verification caches and mining
+// datasets. The zero value selects the canonical ethash parameters; anything
+// else is incompatible with the live network and meant for experimentation.
+type MemoryParams struct {
+	CacheInitBytes     uint64 // Bytes in cache at genesis
+	CacheGrowthBytes   uint64 // Cache growth per epoch
+	DatasetInitBytes   uint64 // Bytes in dataset at genesis
+	DatasetGrowthBytes uint64 // Dataset growth per epoch
+}
+
+// DefaultMemoryParams are the canonical ethash memory parameters.
+var DefaultMemoryParams = MemoryParams{
+	CacheInitBytes:     1 << 24,
+	CacheGrowthBytes:   1 << 17,
+	DatasetInitBytes:   1 << 30,
+	DatasetGrowthBytes: 1 << 23,
+}
+
+// canonical returns whether the parameters are the canonical ethash ones.
+func (p MemoryParams) canonical() bool {
+	return p == MemoryParams{} || p == DefaultMemoryParams
+}
+
+// tag returns a short identifier of non-canonical parameters to keep their disk
+// dumps apart from the canonical ones, or an empty string for canonical ones.
+func (p MemoryParams) tag() string {
+	if p.canonical() {
+		return ""
+	}
+	return fmt.Sprintf("-M%x", crypto.Keccak256([]byte(fmt.Sprintf("%d-%d-%d-%d", p.CacheInitBytes, p.CacheGrowthBytes, p.DatasetInitBytes, p.DatasetGrowthBytes)))[:4])
+}
+
+// cacheSize returns the size of the verification cache that belongs to a certain
+// block number under these memory parameters.
+func (p MemoryParams) cacheSize(block uint64) uint64 {
+	if p.canonical() {
+		return cacheSize(block)
+	}
+	return CalcCacheSize(block/epochLength, p)
+}
+
+// datasetSize returns the size of the mining dataset that belongs to a certain
+// block number under these memory parameters.
+func (p MemoryParams) datasetSize(block uint64) uint64 {
+	if p.canonical() {
+		return datasetSize(block)
+	}
+	return CalcDatasetSize(block/epochLength, p)
+}
+
 // cacheSize returns the size of the ethash verification cache that belongs to a certain
 // block number.
 func cacheSize(block uint64) uint64 {
@@ -55,14 +102,14 @@ func cacheSize(block uint64) uint64 {
 	if epoch < maxEpoch {
 		return cacheSizes[epoch]
 	}
-	return calcCacheSize(epoch)
+	return CalcCacheSize(uint64(epoch), DefaultMemoryParams)
 }
 
-// calcCacheSize calculates the cache size for epoch. The cache size grows linearly,
+// CalcCacheSize calculates the cache size for epoch. The cache size grows linearly,
 // however, we always take the highest prime below the linearly growing threshold in order
 // to reduce the risk of accidental regularities leading to cyclic behavior.
-func calcCacheSize(epoch int) uint64 {
-	size := cacheInitBytes + cacheGrowthBytes*uint64(epoch) - hashBytes
+func CalcCacheSize(epoch uint64, params MemoryParams) uint64 {
+	size := params.CacheInitBytes + params.CacheGrowthBytes*epoch - hashBytes
 	for !new(big.Int).SetUint64(size / hashBytes).ProbablyPrime(1) { // Always accurate for n < 2^64
 		size -= 2 * hashBytes
 	}
@@ -76,14 +123,14 @@ func datasetSize(block uint64) uint64 {
 	if epoch < maxEpoch {
 		return datasetSizes[epoch]
 	}
-	return calcDatasetSize(epoch)
+	return CalcDatasetSize(uint64(epoch), DefaultMemoryParams)
 }
 
-// calcDatasetSize calculates the dataset size for epoch. The dataset size grows linearly,
+// CalcDatasetSize calculates the dataset size for epoch. The dataset size grows linearly,
 // however, we always take the highest prime below the linearly growing threshold in order
 // to reduce the risk of accidental regularities leading to cyclic behavior.
-func calcDatasetSize(epoch int) uint64 {
-	size := datasetInitBytes + datasetGrowthBytes*uint64(epoch) - mixBytes
+func CalcDatasetSize(epoch uint64, params MemoryParams) uint64 {
+	size := params.DatasetInitBytes + params.DatasetGrowthBytes*epoch - mixBytes
 	for !new(big.Int).SetUint64(size / mixBytes).ProbablyPrime(1) { // Always accurate for n < 2^64
 		size -= 2 * mixBytes
 	}
@@ -329,6 +376,40 @@ func generateDataset(dest []uint32, epoch uint64, cache []uint32) {
 	pend.Wait()
 }
 
+// KeccakBackend is a keccak implementation used in the proof-of-work hot path,
+// i.e. hashing the seed and the final result for every header hash and nonce.
+type KeccakBackend struct {
+	Keccak256 func(data ...[]byte) []byte
+	Keccak512 func(data ...[]byte) []byte
+}
+
+// keccakBackend is the currently configured keccak implementation, holding a
+// KeccakBackend. If unset, the crypto package's implementation is used.
+var keccakBackend atomic.Value
+
+// SetKeccakBackend replaces the keccak implementation used in the proof-of-work
+// hot path, e.g. with one optimized for the local CPU. Any nil function falls
+// back to the crypto package's implementation. The backend is process wide, so
+// both mining and verification use the same one. It must produce the exact same
+// results as the default, otherwise valid seals will be rejected.
+func SetKeccakBackend(backend KeccakBackend) {
+	if backend.Keccak256 == nil {
+		backend.Keccak256 = crypto.Keccak256
+	}
+	if backend.Keccak512 == nil {
+		backend.Keccak512 = crypto.Keccak512
+	}
+	keccakBackend.Store(backend)
+}
+
+// keccak returns the currently configured keccak implementation.
+func keccak() KeccakBackend {
+	if backend, ok := keccakBackend.Load().(KeccakBackend); ok {
+		return backend
+	}
+	return KeccakBackend{Keccak256: crypto.Keccak256, Keccak512: crypto.Keccak512}
+}
+
 // hashimoto aggregates data from the full dataset in order to produce our final
 // value for a particular header hash and nonce.
 func hashimoto(hash []byte, nonce uint64, size uint64, lookup func(index uint32) []uint32) ([]byte, []byte) {
@@ -340,7 +421,8 @@ func hashimoto(hash []byte, nonce uint64, size uint64, lookup func(index uint32)
 	copy(seed, hash)
 	binary.LittleEndian.PutUint64(seed[32:], nonce)
 
-	seed = crypto.Keccak512(seed)
+	backend := keccak()
+	seed = backend.Keccak512(seed)
 	seedHead := binary.LittleEndian.Uint32(seed)
 
 	// Start the mix with replicated seed
@@ -368,7 +450,7 @@ func hashimoto(hash []byte, nonce uint64, size uint64, lookup func(index uint32)
 	for i, val := range mix {
 		binary.LittleEndian.PutUint32(digest[i*4:], val)
 	}
-	return digest, crypto.Keccak256(append(seed, digest...))
+	return digest, backend.Keccak256(append(seed, digest...))
 }
 
 // hashimotoLight aggregates data from the full dataset (using only a small
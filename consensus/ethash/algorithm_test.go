@@ -24,11 +24,13 @@ import (
 	"os"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/hexutil"
 	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/crypto"
 )
 
 // prepare converts an ethash cache or dataset from a byte stream into the internal
@@ -45,17 +47,63 @@ func prepare(dest []uint32, src []byte) {
 func TestSizeCalculations(t *testing.T) {
 	// Verify all the cache and dataset sizes from the lookup table.
 	for epoch, want := range cacheSizes {
-		if size := calcCacheSize(epoch); size != want {
+		if size := CalcCacheSize(uint64(epoch), DefaultMemoryParams); size != want {
 			t.Errorf("cache %d: cache size mismatch: have %d, want %d", epoch, size, want)
 		}
 	}
 	for epoch, want := range datasetSizes {
-		if size := calcDatasetSize(epoch); size != want {
+		if size := CalcDatasetSize(uint64(epoch), DefaultMemoryParams); size != want {
 			t.Errorf("dataset %d: dataset size mismatch: have %d, want %d", epoch, size, want)
 		}
 	}
 }
 
+// Tests that custom memory parameters are used for sizing instead of the lookup
+// tables, while the zero value falls back to the canonical ones.
+func TestMemoryParamsSizes(t *testing.T) {
+	block := uint64(5*epochLength + 1)
+	if have, want := (MemoryParams{}).cacheSize(block), cacheSize(block); have != want {
+		t.Errorf("zero params cache size mismatch: have %d, want %d", have, want)
+	}
+	if have, want := (MemoryParams{}).datasetSize(block), datasetSize(block); have != want {
+		t.Errorf("zero params dataset size mismatch: have %d, want %d", have, want)
+	}
+	custom := MemoryParams{CacheInitBytes: 1 << 16, CacheGrowthBytes: 1 << 10, DatasetInitBytes: 1 << 20, DatasetGrowthBytes: 1 << 12}
+	if have, want := custom.cacheSize(block), CalcCacheSize(5, custom); have != want || have >= custom.CacheInitBytes+5*custom.CacheGrowthBytes {
+		t.Errorf("custom params cache size mismatch: have %d, want %d", have, want)
+	}
+	if have, want := custom.datasetSize(block), CalcDatasetSize(5, custom); have != want || have >= custom.DatasetInitBytes+5*custom.DatasetGrowthBytes {
+		t.Errorf("custom params dataset size mismatch: have %d, want %d", have, want)
+	}
+	if (MemoryParams{}).tag() != "" || DefaultMemoryParams.tag() != "" || custom.tag() == "" {
+		t.Errorf("memory params tag mismatch: zero %q, default %q, custom %q", (MemoryParams{}).tag(), DefaultMemoryParams.tag(), custom.tag())
+	}
+}
+
+// Tests that partially set memory parameters fall back to the canonical initial
+// sizes instead of underflowing the size computations.
+func TestPartialMemoryParams(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, MemoryParams: MemoryParams{CacheInitBytes: 1 << 16, CacheGrowthBytes: 1 << 10, DatasetInitBytes: mixBytes}}, nil, false)
+	defer ethash.Close()
+
+	params := ethash.config.MemoryParams
+	if params.CacheInitBytes != 1<<16 || params.CacheGrowthBytes != 1<<10 {
+		t.Errorf("cache params modified: have %d/%d, want %d/%d", params.CacheInitBytes, params.CacheGrowthBytes, 1<<16, 1<<10)
+	}
+	if params.DatasetInitBytes != DefaultMemoryParams.DatasetInitBytes {
+		t.Errorf("dataset init size mismatch: have %d, want %d", params.DatasetInitBytes, DefaultMemoryParams.DatasetInitBytes)
+	}
+	if size := params.datasetSize(0); size > DefaultMemoryParams.DatasetInitBytes {
+		t.Errorf("dataset size out of range: have %d, want at most %d", size, DefaultMemoryParams.DatasetInitBytes)
+	}
+	ethash = New(Config{PowMode: ModeTest, MemoryParams: MemoryParams{DatasetInitBytes: 1 << 20}}, nil, false)
+	defer ethash.Close()
+
+	if size := ethash.config.MemoryParams.cacheSize(0); size > DefaultMemoryParams.CacheInitBytes {
+		t.Errorf("cache size out of range: have %d, want at most %d", size, DefaultMemoryParams.CacheInitBytes)
+	}
+}
+
 // Tests that verification caches can be correctly generated.
 func TestCacheGeneration(t *testing.T) {
 	tests := []struct {
@@ -729,7 +777,7 @@ func TestConcurrentDiskCacheGeneration(t *testing.T) {
 
 		go func(idx int) {
 			defer pend.Done()
-			ethash := New(Config{cachedir, 0, 1, false, "", 0, 0, false, ModeNormal, nil}, nil, false)
+			ethash := New(Config{CacheDir: cachedir, CachesOnDisk: 1, PowMode: ModeNormal}, nil, false)
 			defer ethash.Close()
 			if err := ethash.VerifySeal(nil, block.Header()); err != nil {
 				t.Errorf("proc %d: block verification failed: %v", idx, err)
@@ -788,6 +836,58 @@ func BenchmarkHashimotoFullSmall(b *testing.B) {
 	}
 }
 
+// Tests that the configured keccak backend is used in the hot path.
+func TestKeccakBackend(t *testing.T) {
+	defer SetKeccakBackend(KeccakBackend{})
+
+	cache := make([]uint32, 1024/4)
+	generateCache(cache, 0, make([]byte, 32))
+	hash := hexutil.MustDecode("0xc9149cc0386e689d789a1c2f3d5d169a61a6218ed30e74414dc736e442ef3d1f")
+
+	wantDigest, wantResult := hashimotoLight(32*1024, cache, hash, 0)
+
+	// Install a counting backend and ensure results are unchanged
+	var calls int32
+	SetKeccakBackend(KeccakBackend{
+		Keccak512: func(data ...[]byte) []byte {
+			atomic.AddInt32(&calls, 1)
+			return crypto.Keccak512(data...)
+		},
+	})
+	digest, result := hashimotoLight(32*1024, cache, hash, 0)
+	if !bytes.Equal(digest, wantDigest) || !bytes.Equal(result, wantResult) {
+		t.Errorf("results mismatch with wrapped backend: have %x/%x, want %x/%x", digest, result, wantDigest, wantResult)
+	}
+	if calls != 1 {
+		t.Errorf("backend calls mismatch: have %d, want 1", calls)
+	}
+	// Install a bogus backend and ensure it's picked up
+	bogus := make([]byte, 32)
+	SetKeccakBackend(KeccakBackend{Keccak256: func(data ...[]byte) []byte { return bogus }})
+	if _, result := hashimotoLight(32*1024, cache, hash, 0); !bytes.Equal(result, bogus) {
+		t.Errorf("bogus backend not used: have %x, want %x", result, bogus)
+	}
+}
+
+// Benchmarks the light verification performance with a custom keccak backend,
+// which should match BenchmarkHashimotoLight for a backend wrapping the default.
+func BenchmarkHashimotoLightKeccakBackend(b *testing.B) {
+	defer SetKeccakBackend(KeccakBackend{})
+	SetKeccakBackend(KeccakBackend{
+		Keccak256: func(data ...[]byte) []byte { return crypto.Keccak256(data...) },
+		Keccak512: func(data ...[]byte) []byte { return crypto.Keccak512(data...) },
+	})
+	cache := make([]uint32, cacheSize(1)/4)
+	generateCache(cache, 0, make([]byte, 32))
+
+	hash := hexutil.MustDecode("0xc9149cc0386e689d789a1c2f3d5d169a61a6218ed30e74414dc736e442ef3d1f")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hashimotoLight(datasetSize(1), cache, hash, 0)
+	}
+}
+
 func benchmarkHashimotoFullMmap(b *testing.B, name string, lock bool) {
 	b.Run(name, func(b *testing.B) {
 		tmpdir, err := ioutil.TempDir("", "ethash-test")
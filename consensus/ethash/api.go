@@ -18,13 +18,18 @@ package ethash
 
 import (
 	"errors"
+	"math/big"
+	"reflect"
+	"sort"
+	"time"
+	"unicode"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/hexutil"
 	"github.com/expanse-org/go-expanse/core/types"
 )
 
-var errEthashStopped = errors.New("ethash stopped")
+var errEthashStopped = ErrSealerStopped
 
 // API exposes ethash related methods for the RPC interface.
 type API struct {
@@ -47,6 +52,7 @@ func (api *API) GetWork() ([4]string, error) {
 		workCh = make(chan [4]string, 1)
 		errc   = make(chan error, 1)
 	)
+	api.ethash.remote.start()
 	select {
 	case api.ethash.remote.fetchWorkCh <- &sealWork{errc: errc, res: workCh}:
 	case <-api.ethash.remote.exitCh:
@@ -60,27 +66,84 @@ func (api *API) GetWork() ([4]string, error) {
 	}
 }
 
-// SubmitWork can be used by external miner to submit their POW solution.
-// It returns an indication if the work was accepted.
-// Note either an invalid solution, a stale work a non-existent work will return false.
-func (api *API) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash) bool {
+// GetWorkHeader returns the full header of the block currently being mined, for
+// miners needing more context than the work package, e.g. after reconnecting.
+// The header is encoded in the standard JSON header format.
+func (api *API) GetWorkHeader() (*types.Header, error) {
 	if api.ethash.remote == nil {
-		return false
+		return nil, errors.New("not supported")
+	}
+	req := make(chan *minerState, 1)
+	api.ethash.remote.start()
+	select {
+	case api.ethash.remote.fetchStateCh <- req:
+	case <-api.ethash.remote.exitCh:
+		return nil, errEthashStopped
+	}
+	state := <-req
+	if state.Block == nil {
+		return nil, errNoMiningWork
+	}
+	return state.Block.Header(), nil
+}
+
+// ResendWork pushes the current work package to the remote miners again, e.g.
+// for miners which missed the notification. The work itself is not modified, so
+// that solutions still match the block being sealed. It returns the work package,
+// or an error if nothing is being mined.
+//
+// Picking up a changed extra-data or coinbase requires a new block template,
+// which is up to the miner producing the blocks, not the sealing engine.
+func (api *API) ResendWork() ([4]string, error) {
+	if api.ethash.remote == nil {
+		return [4]string{}, errors.New("not supported")
 	}
 
-	var errc = make(chan error, 1)
+	var (
+		workCh = make(chan [4]string, 1)
+		errc   = make(chan error, 1)
+	)
+	api.ethash.remote.start()
 	select {
-	case api.ethash.remote.submitWorkCh <- &mineResult{
-		nonce:     nonce,
-		mixDigest: digest,
-		hash:      hash,
-		errc:      errc,
-	}:
+	case api.ethash.remote.resendCh <- &sealWork{errc: errc, res: workCh}:
 	case <-api.ethash.remote.exitCh:
-		return false
+		return [4]string{}, errEthashStopped
+	}
+	select {
+	case work := <-workCh:
+		return work, nil
+	case err := <-errc:
+		return [4]string{}, err
+	}
+}
+
+// TestSeal checks the proof-of-work of the given sealed header without importing
+// it, returning whether it's valid along with the recomputed values and target.
+func (api *API) TestSeal(header *types.Header) (*SealCheck, error) {
+	if header == nil || header.Number == nil || header.Difficulty == nil {
+		return nil, errors.New("missing header number or difficulty")
 	}
-	err := <-errc
-	return err == nil
+	return api.ethash.checkSeal(header), nil
+}
+
+// GetWorkEpoch returns a counter incremented whenever new work obsoletes all the
+// previous work packages, allowing miners to cheaply poll for stale work.
+func (api *API) GetWorkEpoch() hexutil.Uint64 {
+	return hexutil.Uint64(api.ethash.WorkEpoch())
+}
+
+// SubmitWork can be used by external miner to submit their POW solution.
+// It returns an indication if the work was accepted.
+// Note either an invalid solution, a stale work, a non-existent work or a duplicate
+// submission will return false.
+func (api *API) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash) bool {
+	return api.ethash.SubmitWork(nonce, hash, digest) == nil
+}
+
+// SubmitWorkFor works like SubmitWork, but also rejects the solution if the work
+// package with the given seal hash is not for the given block number.
+func (api *API) SubmitWorkFor(number hexutil.Uint64, nonce types.BlockNonce, hash, digest common.Hash) bool {
+	return api.ethash.SubmitWorkFor(uint64(number), nonce, hash, digest) == nil
 }
 
 // SubmitHashrate can be used for remote miners to submit their hash rate.
@@ -95,6 +158,7 @@ func (api *API) SubmitHashRate(rate hexutil.Uint64, id common.Hash) bool {
 	}
 
 	var done = make(chan struct{}, 1)
+	api.ethash.remote.start()
 	select {
 	case api.ethash.remote.submitRateCh <- &hashrate{done: done, rate: uint64(rate), id: id}:
 	case <-api.ethash.remote.exitCh:
@@ -102,11 +166,224 @@ func (api *API) SubmitHashRate(rate hexutil.Uint64, id common.Hash) bool {
 	}
 
 	// Block until hash rate submitted successfully.
-	<-done
-	return true
+	select {
+	case <-done:
+		return true
+	case <-api.ethash.remote.exitCh:
+		return false
+	}
+}
+
+// GetShareStats returns the number of valid, stale and invalid remote work
+// submissions since startup or the last reset.
+func (api *API) GetShareStats() (ShareStats, error) {
+	return api.ethash.ShareStats(false)
+}
+
+// GetSeedHash returns the seed hash of the epoch the given block belongs to, as
+// used by miners to select the dataset to mine with.
+func (api *API) GetSeedHash(number hexutil.Uint64) (common.Hash, error) {
+	if err := api.ethash.checkNumber(new(big.Int).SetUint64(uint64(number))); err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(SeedHash(uint64(number))), nil
 }
 
 // GetHashrate returns the current hashrate for local CPU miner and remote miner.
 func (api *API) GetHashrate() uint64 {
 	return uint64(api.ethash.Hashrate())
 }
+
+// GetRemoteMiners returns the remote miners which have recently submitted their
+// hash rate, along with the time of and rate reported in their last submission.
+func (api *API) GetRemoteMiners() []RemoteMinerInfo {
+	return api.ethash.RemoteMiners()
+}
+
+// GetLastSeal returns the seal hash, nonce, mix digest and time of the most
+// recent solution found by the local miner, or an error if none was found yet.
+func (api *API) GetLastSeal() (*SealInfo, error) {
+	return api.ethash.LastSeal()
+}
+
+// GetNonceProgress returns how far into the nonce space each local mining thread
+// has scanned for the current work, along with the nonces remaining before the
+// thread restarts from a fresh seed.
+func (api *API) GetNonceProgress() []NonceProgress {
+	return api.ethash.NonceProgress()
+}
+
+// GetPoWLatency returns percentile summaries of the proof-of-work execution
+// times recorded while verifying seals, if enabled via RecordPoWLatency.
+func (api *API) GetPoWLatency() (PoWLatency, error) {
+	return api.ethash.PoWLatency()
+}
+
+// EngineConfig is the configuration of the engine as reported over RPC. Runtime
+// hooks, the logger, filesystem paths and pool coordination data are omitted.
+type EngineConfig struct {
+	PowMode                Mode          `json:"powMode"`
+	Threads                int           `json:"threads"`
+	CachesInMem            int           `json:"cachesInMem"`
+	CachesOnDisk           int           `json:"cachesOnDisk"`
+	CachesLockMmap         bool          `json:"cachesLockMmap"`
+	DatasetsInMem          int           `json:"datasetsInMem"`
+	DatasetsOnDisk         int           `json:"datasetsOnDisk"`
+	DatasetsLockMmap       bool          `json:"datasetsLockMmap"`
+	MemoryParams           MemoryParams  `json:"memoryParams"`
+	ExtraKeccakRounds      int           `json:"extraKeccakRounds"`
+	CompactTarget          bool          `json:"compactTarget"`
+	NotifyCleanFlag        bool          `json:"notifyCleanFlag"`
+	ResultsBuffer          int           `json:"resultsBuffer"`
+	ResultSendTimeout      time.Duration `json:"resultSendTimeout"`
+	RemoteGCInterval       time.Duration `json:"remoteGCInterval"`
+	MinNotifyInterval      time.Duration `json:"minNotifyInterval"`
+	AllowedFutureBlockTime time.Duration `json:"allowedFutureBlockTime"`
+	MaxBlockNumber         uint64        `json:"maxBlockNumber"`
+	TargetCeiling          *big.Int      `json:"targetCeiling"`
+	MaxVerifyConcurrency   int           `json:"maxVerifyConcurrency"`
+	SelfTestInterval       time.Duration `json:"selfTestInterval"`
+	TargetHashrate         float64       `json:"targetHashrate"`
+	SubmitWorkBuffer       int           `json:"submitWorkBuffer"`
+	SubmitRateBuffer       int           `json:"submitRateBuffer"`
+	LazyRemoteSealer       bool          `json:"lazyRemoteSealer"`
+	HashrateWindow         int           `json:"hashrateWindow"`
+	ObserveOnly            bool          `json:"observeOnly"`
+	RecordPoWLatency       bool          `json:"recordPoWLatency"`
+	ThreadAffinity         []int         `json:"threadAffinity"`
+}
+
+// GetEngineConfig returns the configuration the engine is running with, along
+// with the number of local mining threads, for attaching to support requests.
+func (api *API) GetEngineConfig() EngineConfig {
+	config, threads := api.ethash.EffectiveConfig()
+	return EngineConfig{
+		PowMode:                config.PowMode,
+		Threads:                threads,
+		CachesInMem:            config.CachesInMem,
+		CachesOnDisk:           config.CachesOnDisk,
+		CachesLockMmap:         config.CachesLockMmap,
+		DatasetsInMem:          config.DatasetsInMem,
+		DatasetsOnDisk:         config.DatasetsOnDisk,
+		DatasetsLockMmap:       config.DatasetsLockMmap,
+		MemoryParams:           config.MemoryParams,
+		ExtraKeccakRounds:      config.ExtraKeccakRounds,
+		CompactTarget:          config.CompactTarget,
+		NotifyCleanFlag:        config.NotifyCleanFlag,
+		ResultsBuffer:          config.ResultsBuffer,
+		ResultSendTimeout:      config.ResultSendTimeout,
+		RemoteGCInterval:       config.RemoteGCInterval,
+		MinNotifyInterval:      config.MinNotifyInterval,
+		AllowedFutureBlockTime: config.AllowedFutureBlockTime,
+		MaxBlockNumber:         config.MaxBlockNumber,
+		TargetCeiling:          config.TargetCeiling,
+		MaxVerifyConcurrency:   config.MaxVerifyConcurrency,
+		SelfTestInterval:       config.SelfTestInterval,
+		TargetHashrate:         config.TargetHashrate,
+		SubmitWorkBuffer:       config.SubmitWorkBuffer,
+		SubmitRateBuffer:       config.SubmitRateBuffer,
+		LazyRemoteSealer:       config.LazyRemoteSealer,
+		HashrateWindow:         config.HashrateWindow,
+		ObserveOnly:            config.ObserveOnly,
+		RecordPoWLatency:       config.RecordPoWLatency,
+		ThreadAffinity:         config.ThreadAffinity,
+	}
+}
+
+// EngineInfo identifies the consensus engine and the revision of its algorithm.
+type EngineInfo struct {
+	Name              string `json:"name"`              // Name of the consensus engine
+	AlgorithmRevision int    `json:"algorithmRevision"` // Revision of the PoW algorithm data structures
+}
+
+// GetEngineInfo returns the name of the engine and its algorithm revision, so
+// that clients can confirm they're talking to the consensus engine expected.
+func (api *API) GetEngineInfo() EngineInfo {
+	return EngineInfo{Name: "ethash", AlgorithmRevision: algorithmRevision}
+}
+
+// GetNotifyURLs returns the endpoints notified of new work packages.
+func (api *API) GetNotifyURLs() []string {
+	return api.ethash.NotifyURLs()
+}
+
+// SupportedMethods returns the names of the public RPC methods exposed by the
+// engine, without namespace, allowing clients to detect features before calling
+// them. The names are derived from the API itself, so they always match the
+// methods registered by APIs.
+func (api *API) SupportedMethods() []string {
+	typ := reflect.TypeOf(api)
+
+	methods := make([]string, 0, typ.NumMethod())
+	for i := 0; i < typ.NumMethod(); i++ {
+		name := []rune(typ.Method(i).Name)
+		name[0] = unicode.ToLower(name[0])
+		methods = append(methods, string(name))
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// PrivateAPI exposes ethash methods altering the behaviour of the engine, which
+// must not be available to untrusted RPC users. It is registered in the miner
+// namespace, which is not exposed publicly.
+type PrivateAPI struct {
+	ethash *Ethash
+}
+
+// SetNotifyURLs replaces the endpoints notified of new work packages, taking
+// effect from the next work package pushed.
+func (api *PrivateAPI) SetNotifyURLs(urls []string) error {
+	return api.ethash.SetNotifyURLs(urls)
+}
+
+// SetNotifyEnabled enables or disables pushing new work packages to the notify
+// URLs, leaving local sealing untouched.
+func (api *PrivateAPI) SetNotifyEnabled(enabled bool) error {
+	return api.ethash.SetNotifyEnabled(enabled)
+}
+
+// ExpireWork drops the current mining work immediately, as if it went stale, so
+// that GetWork returns an error until new work is pushed. It is meant for pool
+// integration tests.
+func (api *PrivateAPI) ExpireWork() error {
+	if api.ethash.remote == nil {
+		return errors.New("not supported")
+	}
+	done := make(chan struct{})
+	api.ethash.remote.start()
+	select {
+	case api.ethash.remote.expireCh <- done:
+	case <-api.ethash.remote.exitCh:
+		return errEthashStopped
+	}
+	<-done
+	return nil
+}
+
+// ResetShareStats returns the number of valid, stale and invalid remote work
+// submissions since startup or the last reset, and starts a new count.
+func (api *PrivateAPI) ResetShareStats() (ShareStats, error) {
+	return api.ethash.ShareStats(true)
+}
+
+// SubmitPing is the outcome of a probe submission.
+type SubmitPing struct {
+	Elapsed hexutil.Uint64 `json:"elapsed"` // Processing time of the submission, in microseconds
+	Reason  string         `json:"reason"`  // Reason the submission was rejected
+}
+
+// PingSubmit sends a probe submission through the remote sealer, reporting how
+// long it took to be processed and why it was rejected, so that pools can measure
+// the submission latency. The probe is never accepted. It is not exposed publicly
+// as every probe occupies the remote sealer.
+func (api *PrivateAPI) PingSubmit() (*SubmitPing, error) {
+	if api.ethash.remote == nil {
+		return nil, errors.New("not supported")
+	}
+	elapsed, err := api.ethash.PingSubmit()
+	if err == errEthashStopped {
+		return nil, err
+	}
+	return &SubmitPing{Elapsed: hexutil.Uint64(elapsed.Microseconds()), Reason: err.Error()}, nil
+}
@@ -26,6 +26,7 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
 	"github.com/expanse-org/go-expanse/common/math"
 	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/consensus/misc"
@@ -44,8 +45,21 @@ var (
 	ConstantinopleBlockReward = big.NewInt(4e+18) // Block reward in wei for successfully mining a block upward from Constantinople
 	maxUncles                 = 2                 // Maximum number of uncles allowed in a single block
 	allowedFutureBlockTime    = 15 * time.Second  // Max time from current time allowed for blocks, before they're considered future blocks
+	maxBlockNumber            = uint64(1) << 35   // Default max block number accepted by the epoch computations (~16K years of 15s blocks)
 )
 
+// ErrBlockNumberOutOfRange is returned if a header's number is negative or too
+// large for the epoch computations of the proof-of-work.
+var ErrBlockNumberOutOfRange = errors.New("block number out of range")
+
+// ErrVerifyTimeout is returned if a seal verification doesn't complete within
+// the allotted time.
+var ErrVerifyTimeout = errors.New("seal verification timed out")
+
+// ErrResultMismatch is returned if a recomputed proof-of-work result differs
+// from the one produced by another implementation.
+var ErrResultMismatch = errors.New("proof-of-work result mismatch")
+
 // Various error messages to mark blocks invalid. These should be private to
 // prevent engine specific errors from being referenced in the remainder of the
 // codebase, inherently breaking if the engine is swapped out. Please put common
@@ -59,6 +73,7 @@ var (
 	errInvalidDifficulty = errors.New("non-positive difficulty")
 	errInvalidMixDigest  = errors.New("invalid mix digest")
 	errInvalidPoW        = errors.New("invalid proof-of-work")
+	errMissingChain      = errors.New("chain reader required but missing")
 )
 
 // Author implements consensus.Engine, returning the header's coinbase as the
@@ -74,6 +89,9 @@ func (ethash *Ethash) VerifyHeader(chain consensus.ChainHeaderReader, header *ty
 	if ethash.config.PowMode == ModeFullFake {
 		return nil
 	}
+	if chain == nil {
+		return errMissingChain
+	}
 	// Short circuit if the header is known, or its parent not
 	number := header.Number.Uint64()
 	if chain.GetHeader(header.Hash(), number) != nil {
@@ -152,7 +170,56 @@ func (ethash *Ethash) VerifyHeaders(chain consensus.ChainHeaderReader, headers [
 	return abort, errorsOut
 }
 
+// VerifyStream verifies the seals of the headers received on the input channel
+// using a bounded pool of workers, emitting one result per header in the order
+// they were received. The returned channel is closed after the input channel is
+// closed and all its headers were verified.
+func (ethash *Ethash) VerifyStream(in <-chan *types.Header) <-chan error {
+	// Spawn as many workers as allowed threads
+	workers := runtime.GOMAXPROCS(0)
+
+	type verifyTask struct {
+		header *types.Header
+		result chan error
+	}
+	var (
+		tasks   = make(chan verifyTask)
+		pending = make(chan chan error, workers) // Results in input order, bounding the headers in flight
+		out     = make(chan error, workers)
+	)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range tasks {
+				task.result <- ethash.VerifySeal(nil, task.header)
+			}
+		}()
+	}
+	// Feed the workers, queueing up the result slots in input order
+	go func() {
+		defer close(pending)
+		defer close(tasks)
+
+		for header := range in {
+			result := make(chan error, 1)
+			pending <- result
+			tasks <- verifyTask{header: header, result: result}
+		}
+	}()
+	// Deliver the results in input order
+	go func() {
+		defer close(out)
+
+		for result := range pending {
+			out <- <-result
+		}
+	}()
+	return out
+}
+
 func (ethash *Ethash) verifyHeaderWorker(chain consensus.ChainHeaderReader, headers []*types.Header, seals []bool, index int) error {
+	if chain == nil {
+		return errMissingChain
+	}
 	var parent *types.Header
 	if index == 0 {
 		parent = chain.GetHeader(headers[0].ParentHash, headers[0].Number.Uint64()-1)
@@ -169,10 +236,12 @@ func (ethash *Ethash) verifyHeaderWorker(chain consensus.ChainHeaderReader, head
 }
 
 // VerifyUncles verifies that the given block's uncles conform to the consensus
-// rules of the stock Ethereum ethash engine.
+// rules of the stock Ethereum ethash engine: the uncle count limit and inclusion
+// rules, and the validity of each uncle header including its seal. The first
+// failure encountered is returned.
 func (ethash *Ethash) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
-	// If we're running a full engine faking, accept any input as valid
-	if ethash.config.PowMode == ModeFullFake {
+	// If we're running a full or semi engine faking, accept any uncles as valid
+	if ethash.config.PowMode == ModeFullFake || ethash.config.PowMode == ModeSemiFake {
 		return nil
 	}
 	// Verify that there are at most 2 uncles included in this block
@@ -182,6 +251,9 @@ func (ethash *Ethash) VerifyUncles(chain consensus.ChainReader, block *types.Blo
 	if len(block.Uncles()) == 0 {
 		return nil
 	}
+	if chain == nil {
+		return errMissingChain
+	}
 	// Gather the set of past uncles and ancestors
 	uncles, ancestors := mapset.NewSet(), make(map[common.Hash]*types.Header)
 
@@ -227,24 +299,29 @@ func (ethash *Ethash) VerifyUncles(chain consensus.ChainReader, block *types.Blo
 // stock Ethereum ethash engine.
 // See YP section 4.3.4. "Block Header Validity"
 func (ethash *Ethash) verifyHeader(chain consensus.ChainHeaderReader, header, parent *types.Header, uncle bool, seal bool) error {
+	// If we're running a semi engine faking, only enforce the difficulty rules
+	if ethash.config.PowMode == ModeSemiFake {
+		return ethash.verifyDifficulty(chain, header, parent)
+	}
 	// Ensure that the header's extra-data section is of a reasonable size
 	if uint64(len(header.Extra)) > params.MaximumExtraDataSize {
 		return fmt.Errorf("extra-data too long: %d > %d", len(header.Extra), params.MaximumExtraDataSize)
 	}
 	// Verify the header's timestamp
 	if !uncle {
-		if header.Time > uint64(time.Now().Add(allowedFutureBlockTime).Unix()) {
-			return consensus.ErrFutureBlock
+		drift := ethash.config.AllowedFutureBlockTime
+		if drift <= 0 {
+			drift = allowedFutureBlockTime
 		}
-	}
-	if header.Time <= parent.Time {
+		if err := VerifyTimestamp(parent, header, drift); err != nil {
+			return err
+		}
+	} else if header.Time <= parent.Time {
 		return errOlderBlockTime
 	}
 	// Verify the block's difficulty based on its timestamp and parent's difficulty
-	expected := ethash.CalcDifficulty(chain, header.Time, parent)
-
-	if expected.Cmp(header.Difficulty) != 0 {
-		return fmt.Errorf("invalid difficulty: have %v, want %v", header.Difficulty, expected)
+	if err := ethash.verifyDifficulty(chain, header, parent); err != nil {
+		return err
 	}
 	// Verify that the gas limit is <= 2^63-1
 	cap := uint64(0x7fffffffffffffff)
@@ -286,11 +363,88 @@ func (ethash *Ethash) verifyHeader(chain consensus.ChainHeaderReader, header, pa
 	return nil
 }
 
+// verifyDifficulty checks whether the difficulty of a header matches the one
+// expected based on its timestamp and its parent's difficulty.
+func (ethash *Ethash) verifyDifficulty(chain consensus.ChainHeaderReader, header, parent *types.Header) error {
+	expected := ethash.CalcDifficulty(chain, header.Time, parent)
+
+	if expected.Cmp(header.Difficulty) != 0 {
+		return fmt.Errorf("invalid difficulty: have %v, want %v", header.Difficulty, expected)
+	}
+	return nil
+}
+
+// VerifyTimestamp checks that the header's timestamp is after its parent's and
+// not further ahead of the local clock than the allowed drift.
+func VerifyTimestamp(parent, header *types.Header, allowedFutureDrift time.Duration) error {
+	if header.Time > uint64(time.Now().Add(allowedFutureDrift).Unix()) {
+		return consensus.ErrFutureBlock
+	}
+	if header.Time <= parent.Time {
+		return errOlderBlockTime
+	}
+	return nil
+}
+
 // CalcDifficulty is the difficulty adjustment algorithm. It returns
 // the difficulty that a new block should have when created at time
 // given the parent block's time and difficulty.
+//
+// The difficulty algorithm in force is the calculator registered for the most
+// recent configured fork block, falling back to DefaultDifficultyCalculator.
 func (ethash *Ethash) CalcDifficulty(chain consensus.ChainHeaderReader, time uint64, parent *types.Header) *big.Int {
-	return CalcDifficulty(chain.Config(), time, parent)
+	next := new(big.Int).Add(parent.Number, big1)
+	if difficulty := ethash.scriptedDifficulty(next); difficulty != nil {
+		return difficulty
+	}
+	return ethash.difficultyCalculator(next).CalcDifficulty(chain.Config(), time, parent)
+}
+
+// scriptedDifficulty returns a copy of the difficulty configured for the given
+// block number, or nil if the block's difficulty is not scripted.
+func (ethash *Ethash) scriptedDifficulty(number *big.Int) *big.Int {
+	if number == nil || !number.IsUint64() {
+		return nil
+	}
+	if difficulty, ok := ethash.config.DifficultyByBlock[number.Uint64()]; ok && difficulty != nil {
+		return new(big.Int).Set(difficulty)
+	}
+	return nil
+}
+
+// DifficultyCalculator is a difficulty adjustment algorithm, calculating the
+// difficulty a new block should have when created at time given its parent.
+type DifficultyCalculator interface {
+	CalcDifficulty(config *params.ChainConfig, time uint64, parent *types.Header) *big.Int
+}
+
+// DifficultyCalculatorFunc is an adapter to allow the use of ordinary functions
+// as difficulty calculators.
+type DifficultyCalculatorFunc func(config *params.ChainConfig, time uint64, parent *types.Header) *big.Int
+
+// CalcDifficulty calls f(config, time, parent).
+func (f DifficultyCalculatorFunc) CalcDifficulty(config *params.ChainConfig, time uint64, parent *types.Header) *big.Int {
+	return f(config, time, parent)
+}
+
+// DefaultDifficultyCalculator is the stock difficulty adjustment algorithm,
+// switching between the Ethereum rules based on the chain configuration.
+var DefaultDifficultyCalculator DifficultyCalculator = DifficultyCalculatorFunc(CalcDifficulty)
+
+// difficultyCalculator returns the difficulty calculator in force at the given
+// block number: the one registered for the highest fork block not after it.
+func (ethash *Ethash) difficultyCalculator(number *big.Int) DifficultyCalculator {
+	var (
+		calculator = DefaultDifficultyCalculator
+		activation *big.Int
+	)
+	for fork, calc := range ethash.config.DifficultyCalculators {
+		block := new(big.Int).SetUint64(fork)
+		if block.Cmp(number) <= 0 && (activation == nil || block.Cmp(activation) > 0) {
+			calculator, activation = calc, block
+		}
+	}
+	return calculator
 }
 
 // CalcDifficulty is the difficulty adjustment algorithm. It returns
@@ -491,80 +645,566 @@ func calcDifficultyFrontier(time uint64, parent *types.Header) *big.Int {
 }
 
 // VerifySeal implements consensus.Engine, checking whether the given block satisfies
-// the PoW difficulty requirements.
+// the PoW difficulty requirements. Seals are verified from the header alone, so
+// the chain may be nil.
+//
+// In observe-only mode invalid seals are logged and counted, but accepted.
 func (ethash *Ethash) VerifySeal(chain consensus.ChainHeaderReader, header *types.Header) error {
-	return ethash.verifySeal(chain, header, false)
+	err := ethash.verifySeal(chain, header, false)
+	if ethash.events.active() && header.Number != nil && header.Number.IsUint64() {
+		event := Event{Kind: SealVerified, Number: header.Number.Uint64(), SealHash: ethash.SealHash(header), Nonce: header.Nonce.Uint64()}
+		if err != nil {
+			event.Kind, event.Err = VerifyFailed, err
+		}
+		ethash.events.post(event)
+	}
+	if err != nil && ethash.config.ObserveOnly {
+		ethash.config.Log.Warn("Accepting invalid seal in observe-only mode", "number", header.Number, "hash", header.Hash(), "err", err)
+		if ethash.observed != nil {
+			ethash.observed.Inc(1)
+		}
+		return nil
+	}
+	return err
 }
 
-// verifySeal checks whether a block satisfies the PoW difficulty requirements,
-// either using the usual ethash cache for it, or alternatively using a full DAG
-// to make remote mining fast.
+// VerifySealTimeout is like VerifySeal, but gives up with ErrVerifyTimeout if the
+// verification doesn't complete within the given time, protecting callers such
+// as the import pipeline from stalling on pathological inputs. A verification
+// timing out is left running in the background until it completes.
+func (ethash *Ethash) VerifySealTimeout(chain consensus.ChainHeaderReader, header *types.Header, timeout time.Duration) error {
+	if ethash.shared != nil {
+		return ethash.shared.VerifySealTimeout(chain, header, timeout)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- ethash.VerifySeal(chain, header)
+	}()
+	timer := ethash.clock.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-timer.C():
+		return fmt.Errorf("%w after %v", ErrVerifyTimeout, timeout)
+	}
+}
+
+// verifySeal checks whether a block satisfies the PoW difficulty requirements.
+//
+// If fulldag is false, the PoW is recomputed using the light verification cache,
+// which is what block import uses. If fulldag is true, the full mining DAG is
+// used instead (falling back to the cache while it's being generated) to make
+// checking remote mining results fast; seals passing such verification are
+// remembered as having been produced by this node.
+//
+// Seals produced by this node, either by a local thread or verified remote work,
+// are trusted: their PoW is not recomputed, only the known result is checked
+// against the target.
 func (ethash *Ethash) verifySeal(chain consensus.ChainHeaderReader, header *types.Header, fulldag bool) error {
 	// If we're running a fake PoW, accept any seal as valid
-	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeFullFake {
-		time.Sleep(ethash.fakeDelay)
+	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeSemiFake || ethash.config.PowMode == ModeFullFake {
+		ethash.clock.Sleep(ethash.fakeDelay)
 		if ethash.fakeFail == header.Number.Uint64() {
 			return errInvalidPoW
 		}
+		if _, fail := ethash.fakeFails[header.Number.Uint64()]; fail {
+			return errInvalidPoW
+		}
 		return nil
 	}
 	// If we're running a shared PoW, delegate verification to it
 	if ethash.shared != nil {
 		return ethash.shared.verifySeal(chain, header, fulldag)
 	}
-	// Ensure that we have a valid difficulty for the block
-	if header.Difficulty.Sign() <= 0 {
+	// Ensure that we have a valid difficulty and number for the block
+	if header.Difficulty == nil || header.Difficulty.Sign() <= 0 {
 		return errInvalidDifficulty
 	}
-	// Recompute the digest and PoW values
-	number := header.Number.Uint64()
+	if err := ethash.checkNumber(header.Number); err != nil {
+		return err
+	}
+	ethash.noteEpoch(header.Number.Uint64())
 
-	var (
-		digest []byte
-		result []byte
-	)
+	// If the seal was produced by this node, skip recomputing the PoW
+	sealhash := ethash.SealHash(header)
+	if result, ok := ethash.sealedResult(sealhash, header); ok {
+		if new(big.Int).SetBytes(result).Cmp(ethash.verifyTarget(header)) > 0 {
+			return errInvalidPoW
+		}
+		return nil
+	}
+	// If the seal was verified before, possibly prior to a restart, skip it too
+	if ethash.verified != nil {
+		if result, ok := ethash.verified.result(sealhash, header.Nonce, header.MixDigest); ok {
+			if result.Big().Cmp(ethash.verifyTarget(header)) > 0 {
+				return errInvalidPoW
+			}
+			return nil
+		}
+	}
+	// Recompute the digest and PoW values, bounding the number of concurrent
+	// recomputations if requested
+	if ethash.verifySem != nil {
+		ethash.verifySem <- struct{}{}
+	}
+	if ethash.verifyHook != nil {
+		ethash.verifyHook()
+	}
+	digest, result := ethash.hashimoto(header.Number.Uint64(), sealhash, header.Nonce.Uint64(), fulldag)
+	if ethash.verifySem != nil {
+		<-ethash.verifySem
+	}
+
+	// Verify the calculated values against the ones provided in the header
+	if !bytes.Equal(header.MixDigest[:], digest) {
+		return errInvalidMixDigest
+	}
+	target := ethash.verifyTarget(header)
+	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
+		return errInvalidPoW
+	}
+	if fulldag {
+		ethash.rememberSeal(sealhash, header.Nonce, header.MixDigest, result)
+	}
+	if ethash.verified != nil {
+		if err := ethash.verified.add(sealhash, header.Nonce, header.MixDigest, common.BytesToHash(result)); err != nil {
+			ethash.config.Log.Warn("Failed to persist verified seal", "sealhash", sealhash, "err", err)
+		}
+	}
+	return nil
+}
+
+// hashimoto recomputes the mix digest and PoW result of a seal. If fulldag is
+// requested and the mining dataset is already generated, it is used for a fast
+// computation, otherwise the light verification cache is used.
+func (ethash *Ethash) hashimoto(number uint64, sealhash common.Hash, nonce uint64, fulldag bool) ([]byte, []byte) {
+	if ethash.config.RecordPoWLatency && ethash.latency != nil {
+		defer func(start time.Time) { ethash.latency.Update(int64(time.Since(start))) }(time.Now())
+	}
 	// If fast-but-heavy PoW verification was requested, use an ethash dataset
 	if fulldag {
 		dataset := ethash.dataset(number, true)
 		if dataset.generated() {
-			digest, result = hashimotoFull(dataset.dataset, ethash.SealHash(header).Bytes(), header.Nonce.Uint64())
+			digest, result := hashimotoFull(dataset.dataset, sealhash.Bytes(), nonce)
 
 			// Datasets are unmapped in a finalizer. Ensure that the dataset stays alive
 			// until after the call to hashimotoFull so it's not unmapped while being used.
 			runtime.KeepAlive(dataset)
-		} else {
-			// Dataset not yet generated, don't hang, use a cache instead
-			fulldag = false
+			return digest, ethash.extraRounds(result)
 		}
+		// Dataset not yet generated, don't hang, use a cache instead
 	}
 	// If slow-but-light PoW verification was requested (or DAG not yet ready), use an ethash cache
-	if !fulldag {
-		cache := ethash.cache(number)
+	cache := ethash.cache(number)
+
+	size := ethash.config.MemoryParams.datasetSize(number)
+	if ethash.config.PowMode == ModeTest {
+		size = 32 * 1024
+	}
+	digest, result := hashimotoLight(size, cache.cache, sealhash.Bytes(), nonce)
+
+	// Caches are unmapped in a finalizer. Ensure that the cache stays alive
+	// until after the call to hashimotoLight so it's not unmapped while being used.
+	runtime.KeepAlive(cache)
+	return digest, ethash.extraRounds(result)
+}
 
-		size := datasetSize(number)
-		if ethash.config.PowMode == ModeTest {
-			size = 32 * 1024
+// extraRounds applies the configured number of additional keccak256 passes to a
+// PoW result before it is compared against the target, see ExtraKeccakRounds.
+func (ethash *Ethash) extraRounds(result []byte) []byte {
+	if ethash.config.ExtraKeccakRounds <= 0 {
+		return result
+	}
+	backend := keccak()
+	for i := 0; i < ethash.config.ExtraKeccakRounds; i++ {
+		result = backend.Keccak256(result)
+	}
+	return result
+}
+
+// SealCheck is the outcome of checking the proof-of-work of a sealed header.
+type SealCheck struct {
+	Valid     bool         `json:"valid"`           // Whether the seal is valid
+	Error     string       `json:"error,omitempty"` // Reason of the seal being invalid
+	MixDigest common.Hash  `json:"mixDigest"`       // Recomputed mix digest
+	Result    common.Hash  `json:"result"`          // Recomputed PoW result
+	Target    *hexutil.Big `json:"target"`          // Boundary the result must not exceed
+}
+
+// checkSeal recomputes the proof-of-work of a sealed header and compares it to
+// the target, without consulting or updating any locally sealed work.
+func (ethash *Ethash) checkSeal(header *types.Header) *SealCheck {
+	// If we're running a shared PoW, delegate the check to it
+	if ethash.shared != nil {
+		return ethash.shared.checkSeal(header)
+	}
+	check := new(SealCheck)
+	if header.Difficulty == nil || header.Difficulty.Sign() <= 0 {
+		check.Error = errInvalidDifficulty.Error()
+		return check
+	}
+	if err := ethash.checkNumber(header.Number); err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	check.Target = (*hexutil.Big)(new(big.Int).Set(ethash.verifyTarget(header)))
+
+	// If we're running a fake PoW, there's nothing to recompute
+	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeSemiFake || ethash.config.PowMode == ModeFullFake {
+		if err := ethash.verifySeal(nil, header, false); err != nil {
+			check.Error = err.Error()
+			return check
 		}
-		digest, result = hashimotoLight(size, cache.cache, ethash.SealHash(header).Bytes(), header.Nonce.Uint64())
+		check.Valid = true
+		return check
+	}
+	digest, result := ethash.hashimoto(header.Number.Uint64(), ethash.SealHash(header), header.Nonce.Uint64(), false)
+	check.MixDigest, check.Result = common.BytesToHash(digest), common.BytesToHash(result)
 
-		// Caches are unmapped in a finalizer. Ensure that the cache stays alive
-		// until after the call to hashimotoLight so it's not unmapped while being used.
-		runtime.KeepAlive(cache)
+	switch {
+	case !bytes.Equal(header.MixDigest[:], digest):
+		check.Error = errInvalidMixDigest.Error()
+	case new(big.Int).SetBytes(result).Cmp(check.Target.ToInt()) > 0:
+		check.Error = errInvalidPoW.Error()
+	default:
+		check.Valid = true
 	}
-	// Verify the calculated values against the ones provided in the header
+	return check
+}
+
+// SealQuality recomputes the proof-of-work result of a sealed header, returning
+// it as a number: the lower it is, the harder the seal. This allows comparing
+// the actual work behind competing blocks beyond their difficulty, but it is
+// informational only and not part of the consensus rules. Nil is returned if
+// the header has no valid number or the engine runs a fake PoW.
+func (ethash *Ethash) SealQuality(header *types.Header) *big.Int {
+	// If we're running a shared PoW, delegate the computation to it
+	if ethash.shared != nil {
+		return ethash.shared.SealQuality(header)
+	}
+	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeSemiFake || ethash.config.PowMode == ModeFullFake {
+		return nil
+	}
+	if err := ethash.checkNumber(header.Number); err != nil {
+		return nil
+	}
+	_, result := ethash.hashimoto(header.Number.Uint64(), ethash.SealHash(header), header.Nonce.Uint64(), false)
+	return new(big.Int).SetBytes(result)
+}
+
+// VerifyOnce recomputes and checks the proof-of-work of the given header using
+// the verification cache, without consulting or populating any of the verified
+// seal caches. Unlike VerifySeal it thus always pays the full verification cost,
+// making it suitable for benchmarking verification on a given machine.
+func (ethash *Ethash) VerifyOnce(header *types.Header) error {
+	// If we're running a shared PoW, delegate verification to it
+	if ethash.shared != nil {
+		return ethash.shared.VerifyOnce(header)
+	}
+	// If we're running a fake PoW, there's nothing to recompute
+	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeSemiFake || ethash.config.PowMode == ModeFullFake {
+		return ethash.verifySeal(nil, header, false)
+	}
+	if header.Difficulty == nil || header.Difficulty.Sign() <= 0 {
+		return errInvalidDifficulty
+	}
+	if err := ethash.checkNumber(header.Number); err != nil {
+		return err
+	}
+	digest, result := ethash.hashimoto(header.Number.Uint64(), ethash.SealHash(header), header.Nonce.Uint64(), false)
 	if !bytes.Equal(header.MixDigest[:], digest) {
 		return errInvalidMixDigest
 	}
-	target := new(big.Int).Div(two256, header.Difficulty)
-	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
+	if new(big.Int).SetBytes(result).Cmp(ethash.verifyTarget(header)) > 0 {
 		return errInvalidPoW
 	}
 	return nil
 }
 
+// VerifyAgainst recomputes the proof-of-work result of the given header and
+// checks that it equals the expected one, e.g. as produced by an independent
+// implementation of the algorithm. Any difference is reported in detail, to
+// help pinpoint divergences between implementations.
+func (ethash *Ethash) VerifyAgainst(header *types.Header, expectedResult []byte) error {
+	// If we're running a shared PoW, delegate verification to it
+	if ethash.shared != nil {
+		return ethash.shared.VerifyAgainst(header, expectedResult)
+	}
+	// If we're running a fake PoW, there's nothing to recompute
+	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeSemiFake || ethash.config.PowMode == ModeFullFake {
+		return errors.New("not supported")
+	}
+	if err := ethash.checkNumber(header.Number); err != nil {
+		return err
+	}
+	return ethash.verifyAgainst(header.Number.Uint64(), ethash.SealHash(header), header.Nonce.Uint64(), expectedResult)
+}
+
+// verifyAgainst recomputes the proof-of-work result for the given seal hash and
+// nonce, comparing it byte by byte to the expected one.
+func (ethash *Ethash) verifyAgainst(number uint64, sealhash common.Hash, nonce uint64, expected []byte) error {
+	_, result := ethash.hashimoto(number, sealhash, nonce, false)
+	if bytes.Equal(result, expected) {
+		return nil
+	}
+	if len(result) != len(expected) {
+		return fmt.Errorf("%w: have %d bytes, want %d (have %x, want %x)", ErrResultMismatch, len(result), len(expected), result, expected)
+	}
+	var diffs []int
+	for i := range result {
+		if result[i] != expected[i] {
+			diffs = append(diffs, i)
+		}
+	}
+	return fmt.Errorf("%w: %d bytes differ, first at offset %d (have %x, want %x)", ErrResultMismatch, len(diffs), diffs[0], result, expected)
+}
+
+// TotalDifficulty verifies the seal of each header in the given chain segment and
+// returns the cumulative difficulty of the segment. If a seal fails verification,
+// the difficulty summed up to (but excluding) the offending header is returned
+// along with the verification error, so callers can still account for the valid
+// prefix of the segment.
+func (ethash *Ethash) TotalDifficulty(headers []*types.Header) (*big.Int, error) {
+	td := new(big.Int)
+	for _, header := range headers {
+		if err := ethash.VerifySeal(nil, header); err != nil {
+			return td, err
+		}
+		td.Add(td, header.Difficulty)
+	}
+	return td, nil
+}
+
+// VerifyTransition audits the seals of a chain segment crossing a change of the
+// proof-of-work algorithm, e.g. from ethash to frkhash: headers before the fork
+// block must verify under the before engine, the fork block and later ones under
+// the after engine. The first anomaly found is reported. The chain data is not
+// consulted, so only the seals are checked. Headers without a number can't be
+// assigned to either side of the fork and are rejected.
+func VerifyTransition(before, after consensus.Engine, headers []*types.Header, forkBlock uint64) error {
+	for i, header := range headers {
+		if header == nil || header.Number == nil {
+			return fmt.Errorf("header %d: missing header number", i)
+		}
+		engine, era := before, "pre-fork"
+		if header.Number.Uint64() >= forkBlock {
+			engine, era = after, "post-fork"
+		}
+		if err := engine.VerifySeal(nil, header); err != nil {
+			return fmt.Errorf("%s header #%d (%x): %w", era, header.Number, header.Hash(), err)
+		}
+	}
+	return nil
+}
+
+// sealResult is the outcome of a PoW computation for a block sealed by this node.
+type sealResult struct {
+	nonce  types.BlockNonce
+	digest common.Hash
+	result []byte
+}
+
+// rememberSeal records the PoW result of a block sealed by this node, so that a
+// subsequent verification of the same seal can skip recomputing it.
+func (ethash *Ethash) rememberSeal(sealhash common.Hash, nonce types.BlockNonce, digest common.Hash, result []byte) {
+	if ethash.sealed == nil {
+		return
+	}
+	ethash.sealedLock.Lock()
+	defer ethash.sealedLock.Unlock()
+
+	ethash.sealed.Add(sealhash, sealResult{nonce: nonce, digest: digest, result: result})
+}
+
+// sealedResult retrieves the PoW result of the given header if it was sealed by
+// this node with the exact same nonce and mix digest.
+func (ethash *Ethash) sealedResult(sealhash common.Hash, header *types.Header) ([]byte, bool) {
+	if ethash.sealed == nil {
+		return nil, false
+	}
+	ethash.sealedLock.Lock()
+	defer ethash.sealedLock.Unlock()
+
+	item, ok := ethash.sealed.Get(sealhash)
+	if !ok {
+		return nil, false
+	}
+	seal := item.(sealResult)
+	if seal.nonce != header.Nonce || seal.digest != header.MixDigest {
+		return nil, false
+	}
+	return seal.result, true
+}
+
+// CalcTarget returns the PoW boundary condition of the given header, i.e. the
+// largest result value (2^256/difficulty) a valid seal may produce.
+//
+// Headers with a missing or non-positive difficulty are invalid, and callers are
+// expected to reject them beforehand. To avoid dividing by zero, the target of
+// such headers is zero, which no realistic seal can satisfy.
+func CalcTarget(header *types.Header) *big.Int {
+	if header.Difficulty == nil || header.Difficulty.Sign() <= 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).Div(two256, header.Difficulty)
+}
+
+// CompactToTarget decodes a PoW target from the compact "nBits" encoding used by
+// Bitcoin tooling. The most significant byte of the encoding is the size of the
+// target in bytes (the exponent), and the low 23 bits are its most significant
+// bytes (the mantissa), i.e. target = mantissa * 256^(exponent-3). Bit 23 is a
+// sign bit: as targets are never negative, encodings with it set decode to zero.
+func CompactToTarget(nBits uint32) *big.Int {
+	if nBits&0x00800000 != 0 {
+		return new(big.Int)
+	}
+	exponent, mantissa := uint(nBits>>24), int64(nBits&0x007fffff)
+	if exponent <= 3 {
+		return big.NewInt(mantissa >> (8 * (3 - exponent)))
+	}
+	return new(big.Int).Lsh(big.NewInt(mantissa), 8*(exponent-3))
+}
+
+// TargetToCompact encodes a PoW target in the compact "nBits" form described at
+// CompactToTarget. Only the 3 most significant bytes of the target are kept (2
+// if the top one would set the sign bit), so the decoded target is rounded down
+// to a slightly harder one. Missing or non-positive targets encode to zero.
+func TargetToCompact(target *big.Int) uint32 {
+	if target == nil || target.Sign() <= 0 {
+		return 0
+	}
+	size := uint((target.BitLen() + 7) / 8)
+
+	var mantissa uint32
+	if size <= 3 {
+		mantissa = uint32(target.Uint64() << (8 * (3 - size)))
+	} else {
+		mantissa = uint32(new(big.Int).Rsh(target, 8*(size-3)).Uint64())
+	}
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		size++
+	}
+	return uint32(size)<<24 | mantissa
+}
+
+// calcTarget returns the PoW boundary of the given header just like CalcTarget,
+// but memoizes the targets of recently seen difficulties, as these repeat often
+// on stable chains. The returned value is shared and must not be modified.
+func (ethash *Ethash) calcTarget(header *types.Header) *big.Int {
+	if ethash.targets == nil || header.Difficulty == nil || header.Difficulty.Sign() <= 0 {
+		return CalcTarget(header)
+	}
+	key := string(header.Difficulty.Bytes())
+
+	ethash.targetLock.Lock()
+	cached, ok := ethash.targets.Get(key)
+	ethash.targetLock.Unlock()
+	if ok {
+		return cached.(*big.Int)
+	}
+	target := CalcTarget(header)
+
+	ethash.targetLock.Lock()
+	ethash.targets.Add(key, target)
+	ethash.targetLock.Unlock()
+	return target
+}
+
+// verifyTarget returns the PoW boundary a seal of the given header is verified
+// against: the header's own target, relaxed to the configured target ceiling if
+// that is easier. The returned value may be shared and must not be modified.
+func (ethash *Ethash) verifyTarget(header *types.Header) *big.Int {
+	target := ethash.calcTarget(header)
+	if ceiling := ethash.config.TargetCeiling; ceiling != nil && target.Cmp(ceiling) < 0 {
+		return new(big.Int).Set(ceiling)
+	}
+	return target
+}
+
+// checkNumber ensures a block number is non-negative and within the configured
+// bound, so that the epoch computations neither operate on truncated values nor
+// attempt to generate absurdly sized caches and datasets.
+func (ethash *Ethash) checkNumber(number *big.Int) error {
+	limit := ethash.config.MaxBlockNumber
+	if limit == 0 {
+		limit = maxBlockNumber
+	}
+	if number == nil || number.Sign() < 0 || !number.IsUint64() || number.Uint64() > limit {
+		return fmt.Errorf("%w: %v", ErrBlockNumberOutOfRange, number)
+	}
+	return nil
+}
+
+// EstimateNetworkHashrate estimates the hash rate of the network (hashes per
+// second) from a consecutive run of headers ordered by number, dividing the work
+// done to mine all but the first header by the time elapsed since the first one.
+// Zero is returned if the headers don't span any time.
+func EstimateNetworkHashrate(blocks []*types.Header) float64 {
+	if len(blocks) < 2 {
+		return 0
+	}
+	first, last := blocks[0], blocks[len(blocks)-1]
+	if last.Time <= first.Time {
+		return 0
+	}
+	work := new(big.Int)
+	for _, header := range blocks[1:] {
+		work.Add(work, header.Difficulty)
+	}
+	hashrate, _ := new(big.Float).Quo(new(big.Float).SetInt(work), new(big.Float).SetUint64(last.Time-first.Time)).Float64()
+	return hashrate
+}
+
+// EstimateBlockTime returns the expected time needed to find a block of the
+// given difficulty at the given hash rate (hashes per second), i.e. the number
+// of hashes expected to be tried divided by the hash rate. If no block can be
+// expected to be found (non-positive hash rate or difficulty), or the estimate
+// overflows, the maximum representable duration is returned.
+func EstimateBlockTime(hashrate float64, difficulty *big.Int) time.Duration {
+	if difficulty == nil || difficulty.Sign() <= 0 || hashrate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	seconds := new(big.Float).Quo(new(big.Float).SetInt(difficulty), big.NewFloat(hashrate))
+	nanos, _ := seconds.Mul(seconds, big.NewFloat(float64(time.Second))).Int(nil)
+	if !nanos.IsInt64() {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(nanos.Int64())
+}
+
+// ExpectedShareRate returns the number of shares per second expected to be found
+// at the given share difficulty by a miner with the given hash rate (hashes per
+// second), i.e. the hash rate divided by the share difficulty. Zero is returned
+// if the share difficulty is not positive, as no such shares can be accounted.
+func ExpectedShareRate(hashrate float64, shareDifficulty *big.Int) float64 {
+	if shareDifficulty == nil || shareDifficulty.Sign() <= 0 || hashrate <= 0 {
+		return 0
+	}
+	rate, _ := new(big.Float).Quo(big.NewFloat(hashrate), new(big.Float).SetInt(shareDifficulty)).Float64()
+	return rate
+}
+
+// MinDifficultyFor returns the highest difficulty the given proof-of-work result
+// still satisfies, i.e. 2^256 divided by the result, allowing pools to value a
+// found solution. An all-zero result satisfies any difficulty, for which 2^256
+// is returned.
+func MinDifficultyFor(result []byte) *big.Int {
+	value := new(big.Int).SetBytes(result)
+	if value.Sign() == 0 {
+		return new(big.Int).Set(two256)
+	}
+	return value.Div(two256, value)
+}
+
 // Prepare implements consensus.Engine, initializing the difficulty field of a
 // header to conform to the ethash protocol. The changes are done inline.
 func (ethash *Ethash) Prepare(chain consensus.ChainHeaderReader, header *types.Header) error {
+	if chain == nil {
+		return errMissingChain
+	}
 	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
 	if parent == nil {
 		return consensus.ErrUnknownAncestor
@@ -573,6 +1213,24 @@ func (ethash *Ethash) Prepare(chain consensus.ChainHeaderReader, header *types.H
 	return nil
 }
 
+// PrepareAndValidate is like Prepare, but only initializes the difficulty field
+// of a header if it's unset. A preset difficulty is checked against the one
+// calculated from the parent instead, catching broken block templates before
+// any effort is spent sealing them.
+func (ethash *Ethash) PrepareAndValidate(chain consensus.ChainHeaderReader, header *types.Header) error {
+	if chain == nil {
+		return errMissingChain
+	}
+	if header.Difficulty == nil {
+		return ethash.Prepare(chain, header)
+	}
+	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	return ethash.verifyDifficulty(chain, header, parent)
+}
+
 // Finalize implements consensus.Engine, accumulating the block and uncle rewards,
 // setting the final state on the header
 func (ethash *Ethash) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header) {
@@ -584,14 +1242,37 @@ func (ethash *Ethash) Finalize(chain consensus.ChainHeaderReader, header *types.
 // FinalizeAndAssemble implements consensus.Engine, accumulating the block and
 // uncle rewards, setting the final state and assembling the block.
 func (ethash *Ethash) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	if chain == nil {
+		return nil, errMissingChain
+	}
 	// Accumulate any block and uncle rewards and commit the final state root
 	accumulateRewards(chain.Config(), state, header, uncles)
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 
+	// Stamp the extra-nonce region before the seal hash is taken, unless the
+	// extra-data is mandated by the DAO fork
+	if nonce := ethash.config.ExtraNonce; len(nonce) > 0 {
+		extra := header.Extra
+		header.Extra = applyExtraNonce(extra, nonce)
+		if misc.VerifyDAOHeaderExtraData(chain.Config(), header) != nil {
+			header.Extra = extra
+		}
+	}
 	// Header seems complete, assemble into a block and return
 	return types.NewBlock(header, txs, uncles, receipts, new(trie.Trie)), nil
 }
 
+// applyExtraNonce places the extra-nonce at the end of the given extra-data,
+// truncating the original content if needed to stay within the allowed size.
+// The extra-nonce itself is checked against the limit when the engine is created.
+func applyExtraNonce(extra, nonce []byte) []byte {
+	keep := len(extra)
+	if limit := int(params.MaximumExtraDataSize) - len(nonce); keep > limit {
+		keep = limit
+	}
+	return append(common.CopyBytes(extra[:keep]), nonce...)
+}
+
 // SealHash returns the hash of a block prior to it being sealed.
 func (ethash *Ethash) SealHash(header *types.Header) (hash common.Hash) {
 	hasher := sha3.NewLegacyKeccak256()
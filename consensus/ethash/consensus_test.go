@@ -17,14 +17,23 @@
 package ethash
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
 	"github.com/expanse-org/go-expanse/common/math"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/crypto"
 	"github.com/expanse-org/go-expanse/params"
 )
 
@@ -84,3 +93,740 @@ func TestCalcDifficulty(t *testing.T) {
 		}
 	}
 }
+
+func TestCalcTarget(t *testing.T) {
+	tests := []struct {
+		difficulty *big.Int
+		target     *big.Int
+	}{
+		{big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), 256)},
+		{big.NewInt(2), new(big.Int).Lsh(big.NewInt(1), 255)},
+		{big.NewInt(1024), new(big.Int).Lsh(big.NewInt(1), 246)},
+		{big.NewInt(3), math.MustParseBig256("0x5555555555555555555555555555555555555555555555555555555555555555")},
+	}
+	for i, test := range tests {
+		header := &types.Header{Number: big.NewInt(1), Difficulty: test.difficulty}
+		if have := CalcTarget(header); have.Cmp(test.target) != 0 {
+			t.Errorf("test %d: target mismatch: have %x, want %x", i, have, test.target)
+		}
+	}
+}
+
+// Tests that memoized targets match the computed ones, also when difficulties
+// repeat or the cache overflows.
+func TestCachedTarget(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	for round := 0; round < 2; round++ {
+		for i := 1; i <= 2*targetCacheSize; i++ {
+			header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(int64(i) * 1000)}
+			if have, want := ethash.calcTarget(header), CalcTarget(header); have.Cmp(want) != 0 {
+				t.Errorf("round %d, difficulty %v: target mismatch: have %x, want %x", round, header.Difficulty, have, want)
+			}
+		}
+	}
+}
+
+func TestVerifyTimestamp(t *testing.T) {
+	var (
+		drift  = 15 * time.Second
+		now    = uint64(time.Now().Unix())
+		limit  = now + uint64(drift/time.Second)
+		parent = &types.Header{Time: now - 10}
+	)
+	tests := []struct {
+		time uint64
+		err  error
+	}{
+		{now, nil},                            // current time
+		{limit, nil},                          // exactly at the drift boundary
+		{limit + 5, consensus.ErrFutureBlock}, // over the drift
+		{parent.Time, errOlderBlockTime},      // same as parent
+	}
+	for i, tt := range tests {
+		if err := VerifyTimestamp(parent, &types.Header{Time: tt.time}, drift); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+func TestEstimateBlockTime(t *testing.T) {
+	tests := []struct {
+		hashrate   float64
+		difficulty *big.Int
+		want       time.Duration
+	}{
+		{1000, big.NewInt(15000), 15 * time.Second},
+		{2e6, big.NewInt(1e6), 500 * time.Millisecond},
+		{1, new(big.Int).Lsh(big.NewInt(1), 128), time.Duration(math.MaxInt64)}, // overflow
+		{0, big.NewInt(1000), time.Duration(math.MaxInt64)},                     // no hash rate
+		{1000, big.NewInt(0), time.Duration(math.MaxInt64)},                     // zero difficulty
+		{1000, big.NewInt(-1), time.Duration(math.MaxInt64)},                    // negative difficulty
+		{1000, nil, time.Duration(math.MaxInt64)},                               // missing difficulty
+	}
+	for i, tt := range tests {
+		if have := EstimateBlockTime(tt.hashrate, tt.difficulty); have != tt.want {
+			t.Errorf("test %d: estimate mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+func TestExpectedShareRate(t *testing.T) {
+	tests := []struct {
+		hashrate   float64
+		difficulty *big.Int
+		want       float64
+	}{
+		{1e6, big.NewInt(1e6), 1},
+		{30e6, big.NewInt(4e9), 0.0075},
+		{250e6, big.NewInt(1e6), 250},
+		{1e6, big.NewInt(0), 0},  // zero share difficulty
+		{1e6, big.NewInt(-1), 0}, // negative share difficulty
+		{1e6, nil, 0},            // missing share difficulty
+		{0, big.NewInt(1e6), 0},  // no hash rate
+	}
+	for i, tt := range tests {
+		if have := ExpectedShareRate(tt.hashrate, tt.difficulty); have != tt.want {
+			t.Errorf("test %d: share rate mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+func TestMinDifficultyFor(t *testing.T) {
+	tests := []struct {
+		result string
+		want   *big.Int
+	}{
+		{"0xd3539235ee2e6f8db665c0a72169f55b7f6c605712330b778ec3944f0eb5a557", big.NewInt(1)}, // known-answer hashimoto result
+		{"0x00000000000000000000000000000000000000000000000000000000000000ff", math.MustParseBig256("454086624460063511464984254936031011189294057512315937409637584344757371137")},
+		{"0x0000000000000000000000000000000000000000000000000000000000000001", two256},
+		{"0x0000000000000000000000000000000000000000000000000000000000000000", two256}, // all-zero result
+	}
+	for i, tt := range tests {
+		result := hexutil.MustDecode(tt.result)
+		have := MinDifficultyFor(result)
+		if have.Cmp(tt.want) != 0 {
+			t.Errorf("test %d: difficulty mismatch: have %v, want %v", i, have, tt.want)
+			continue
+		}
+		// The result must satisfy the difficulty, but not a harder one
+		value := new(big.Int).SetBytes(result)
+		if value.Cmp(new(big.Int).Div(two256, have)) > 0 {
+			t.Errorf("test %d: result doesn't satisfy difficulty %v", i, have)
+		}
+		if harder := new(big.Int).Add(have, big1); value.Sign() > 0 && value.Cmp(new(big.Int).Div(two256, harder)) <= 0 {
+			t.Errorf("test %d: result satisfies harder difficulty %v", i, harder)
+		}
+	}
+}
+
+func TestCompactTarget(t *testing.T) {
+	// Known encodings, as used by Bitcoin
+	tests := []struct {
+		nBits  uint32
+		target *big.Int
+	}{
+		{0x1d00ffff, new(big.Int).Lsh(big.NewInt(0xffff), 208)}, // Bitcoin genesis target
+		{0x03123456, big.NewInt(0x123456)},
+		{0x01120000, big.NewInt(0x12)},
+		{0x02008000, big.NewInt(0x80)}, // mantissa shifted to keep the sign bit clear
+		{0x21010000, two256},           // difficulty 1
+		{0x00000000, new(big.Int)},
+	}
+	for i, tt := range tests {
+		if have := CompactToTarget(tt.nBits); have.Cmp(tt.target) != 0 {
+			t.Errorf("test %d: decoded target mismatch: have %x, want %x", i, have, tt.target)
+		}
+		if have := TargetToCompact(tt.target); have != tt.nBits {
+			t.Errorf("test %d: encoding mismatch: have %#08x, want %#08x", i, have, tt.nBits)
+		}
+	}
+	if have := CompactToTarget(0x04923456); have.Sign() != 0 {
+		t.Errorf("negative encoding decoded to %x, want 0", have)
+	}
+	// Round trip the targets of various difficulties, which may only lose precision
+	for _, difficulty := range []int64{1, 2, 100, 131072, 1e9, 3e12, 1e15, math.MaxInt64} {
+		target := new(big.Int).Div(two256, big.NewInt(difficulty))
+		nBits := TargetToCompact(target)
+
+		decoded := CompactToTarget(nBits)
+		if decoded.Cmp(target) > 0 || decoded.Cmp(new(big.Int).Sub(target, new(big.Int).Rsh(target, 15))) < 0 {
+			t.Errorf("difficulty %d: decoded target %x too far from %x", difficulty, decoded, target)
+		}
+		if have := TargetToCompact(decoded); have != nBits {
+			t.Errorf("difficulty %d: re-encoding mismatch: have %#08x, want %#08x", difficulty, have, nBits)
+		}
+	}
+}
+
+func TestEstimateNetworkHashrate(t *testing.T) {
+	header := func(time uint64, difficulty int64) *types.Header {
+		return &types.Header{Time: time, Difficulty: big.NewInt(difficulty)}
+	}
+	tests := []struct {
+		blocks []*types.Header
+		want   float64
+	}{
+		{nil, 0},
+		{[]*types.Header{header(100, 1000)}, 0}, // single header
+		{[]*types.Header{header(100, 1000), header(100, 1000)}, 0},                      // no time span
+		{[]*types.Header{header(100, 1000), header(110, 1500)}, 150},                    // one interval
+		{[]*types.Header{header(100, 1000), header(110, 1500), header(130, 3000)}, 150}, // multiple intervals
+	}
+	for i, tt := range tests {
+		if have := EstimateNetworkHashrate(tt.blocks); have != tt.want {
+			t.Errorf("test %d: estimate mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the cumulative difficulty of a chain segment is only accounted for
+// the headers preceding the first invalid seal.
+func TestTotalDifficulty(t *testing.T) {
+	var headers []*types.Header
+	for i := int64(1); i <= 5; i++ {
+		headers = append(headers, &types.Header{Number: big.NewInt(i), Difficulty: big.NewInt(100 * i)})
+	}
+	// Verify a fully valid segment
+	ethash := NewFaker()
+	defer ethash.Close()
+
+	td, err := ethash.TotalDifficulty(headers)
+	if err != nil {
+		t.Fatalf("failed to verify valid segment: %v", err)
+	}
+	if td.Cmp(big.NewInt(1500)) != 0 {
+		t.Errorf("total difficulty mismatch: have %v, want %v", td, 1500)
+	}
+	// Verify a segment with an invalid seal in the middle
+	failer := NewFakeFailer(3)
+	defer failer.Close()
+
+	td, err = failer.TotalDifficulty(headers)
+	if !errors.Is(err, errInvalidPoW) {
+		t.Fatalf("error mismatch: have %v, want %v", err, errInvalidPoW)
+	}
+	if td.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("partial total difficulty mismatch: have %v, want %v", td, 300)
+	}
+}
+
+// testChainReader is a consensus.ChainReader backed by a set of headers and
+// blocks.
+type testChainReader struct {
+	config  *params.ChainConfig
+	headers map[common.Hash]*types.Header
+	blocks  map[common.Hash]*types.Block
+}
+
+func (r *testChainReader) Config() *params.ChainConfig  { return r.config }
+func (r *testChainReader) CurrentHeader() *types.Header { return nil }
+
+func (r *testChainReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := r.headers[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+func (r *testChainReader) GetHeaderByNumber(number uint64) *types.Header {
+	for _, header := range r.headers {
+		if header.Number.Uint64() == number {
+			return header
+		}
+	}
+	return nil
+}
+
+func (r *testChainReader) GetHeaderByHash(hash common.Hash) *types.Header {
+	return r.headers[hash]
+}
+
+func (r *testChainReader) GetBlock(hash common.Hash, number uint64) *types.Block {
+	if block := r.blocks[hash]; block != nil && block.NumberU64() == number {
+		return block
+	}
+	return nil
+}
+
+// Tests that the semi faker skips all header checks apart from the difficulty.
+func TestSemiFaker(t *testing.T) {
+	parent := &types.Header{Number: big.NewInt(1), Time: 100, Difficulty: big.NewInt(131072), GasLimit: 5000}
+	chain := &testChainReader{
+		config:  params.TestChainConfig,
+		headers: map[common.Hash]*types.Header{parent.Hash(): parent},
+	}
+	ethash := NewSemiFaker()
+	defer ethash.Close()
+
+	// Create a header with a valid difficulty, but otherwise invalid fields
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     big.NewInt(2),
+		Time:       110,
+		GasLimit:   1,
+		GasUsed:    2,
+		Extra:      make([]byte, params.MaximumExtraDataSize+1),
+	}
+	header.Difficulty = CalcDifficulty(chain.config, header.Time, parent)
+	if err := ethash.VerifyHeader(chain, header, true); err != nil {
+		t.Fatalf("header with valid difficulty rejected: %v", err)
+	}
+	if err := NewFaker().VerifyHeader(chain, header, true); err == nil {
+		t.Fatalf("invalid header accepted by faker")
+	}
+	// Ensure a bogus difficulty is still rejected
+	header.Difficulty = big.NewInt(1)
+	if err := ethash.VerifyHeader(chain, header, true); err == nil {
+		t.Fatalf("header with invalid difficulty accepted")
+	}
+}
+
+// Tests that a header's difficulty is initialized if unset, and checked against
+// the parent's otherwise.
+func TestPrepareAndValidate(t *testing.T) {
+	parent := &types.Header{Number: big.NewInt(1), Time: 100, Difficulty: big.NewInt(131072)}
+	chain := &testChainReader{
+		config:  params.TestChainConfig,
+		headers: map[common.Hash]*types.Header{parent.Hash(): parent},
+	}
+	ethash := NewFaker()
+	defer ethash.Close()
+
+	want := CalcDifficulty(chain.config, 110, parent)
+	header := &types.Header{ParentHash: parent.Hash(), Number: big.NewInt(2), Time: 110}
+	if err := ethash.PrepareAndValidate(chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	if header.Difficulty.Cmp(want) != 0 {
+		t.Fatalf("prepared difficulty mismatch: have %v, want %v", header.Difficulty, want)
+	}
+	// A matching preset difficulty is accepted as is
+	if err := ethash.PrepareAndValidate(chain, header); err != nil {
+		t.Fatalf("matching difficulty rejected: %v", err)
+	}
+	// A mismatching one is rejected and left untouched
+	header.Difficulty = new(big.Int).Add(want, common.Big1)
+	if err := ethash.PrepareAndValidate(chain, header); err == nil {
+		t.Fatalf("mismatching difficulty accepted")
+	}
+	if header.Difficulty.Cmp(want) == 0 {
+		t.Fatalf("mismatching difficulty overwritten")
+	}
+	// Unknown parents are reported
+	header.ParentHash = common.Hash{0x01}
+	if err := ethash.PrepareAndValidate(chain, header); err != consensus.ErrUnknownAncestor {
+		t.Fatalf("unknown parent error mismatch: have %v, want %v", err, consensus.ErrUnknownAncestor)
+	}
+}
+
+// Tests that the difficulty calculator registered for the most recent fork is
+// used, falling back to the default one before the first fork.
+func TestDifficultyCalculators(t *testing.T) {
+	fixed := func(difficulty int64) DifficultyCalculator {
+		return DifficultyCalculatorFunc(func(*params.ChainConfig, uint64, *types.Header) *big.Int {
+			return big.NewInt(difficulty)
+		})
+	}
+	ethash := NewFaker()
+	defer ethash.Close()
+	ethash.config.DifficultyCalculators = map[uint64]DifficultyCalculator{
+		10: fixed(1000),
+		20: fixed(2000),
+	}
+	chain := &testChainReader{config: params.TestChainConfig}
+
+	tests := []struct {
+		parent uint64
+		want   *big.Int
+	}{
+		{8, nil}, // default algorithm
+		{9, big.NewInt(1000)},
+		{18, big.NewInt(1000)},
+		{19, big.NewInt(2000)},
+		{100, big.NewInt(2000)},
+	}
+	for _, tt := range tests {
+		parent := &types.Header{Number: new(big.Int).SetUint64(tt.parent), Time: 100, Difficulty: big.NewInt(131072)}
+		want := tt.want
+		if want == nil {
+			want = CalcDifficulty(chain.config, 110, parent)
+		}
+		if have := ethash.CalcDifficulty(chain, 110, parent); have.Cmp(want) != 0 {
+			t.Errorf("parent %d: difficulty mismatch: have %v, want %v", tt.parent, have, want)
+		}
+	}
+}
+
+// Tests that the seals of a block's uncles are verified along with the uncle
+// inclusion rules.
+func TestVerifyUncles(t *testing.T) {
+	chain := &testChainReader{
+		config:  params.TestChainConfig,
+		headers: make(map[common.Hash]*types.Header),
+		blocks:  make(map[common.Hash]*types.Block),
+	}
+	child := func(parent *types.Header, coinbase byte) *types.Header {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Coinbase:   common.Address{coinbase},
+			Number:     new(big.Int).Add(parent.Number, common.Big1),
+			Time:       parent.Time + 10,
+			GasLimit:   parent.GasLimit,
+		}
+		header.Difficulty = CalcDifficulty(chain.config, header.Time, parent)
+		return header
+	}
+	insert := func(header *types.Header, uncles ...*types.Header) *types.Block {
+		block := types.NewBlockWithHeader(header).WithBody(nil, uncles)
+		chain.headers[block.Hash()], chain.blocks[block.Hash()] = block.Header(), block
+		return block
+	}
+	// Build a chain where the head includes a sibling of its parent as uncle
+	genesis := insert(&types.Header{Number: common.Big0, Difficulty: big.NewInt(131072), GasLimit: 5000})
+	block1 := insert(child(genesis.Header(), 1))
+	block2 := insert(child(block1.Header(), 1))
+	uncle := child(block1.Header(), 2)
+	head := types.NewBlockWithHeader(child(block2.Header(), 1)).WithBody(nil, []*types.Header{uncle})
+
+	valid := NewFaker()
+	defer valid.Close()
+	if err := valid.VerifyUncles(chain, head); err != nil {
+		t.Fatalf("valid uncle rejected: %v", err)
+	}
+	// Ensure an uncle carrying an invalid seal is rejected
+	failer := NewFakeFailer(uncle.Number.Uint64())
+	defer failer.Close()
+	if err := failer.VerifyUncles(chain, head); !errors.Is(err, errInvalidPoW) {
+		t.Fatalf("invalid uncle seal error mismatch: have %v, want %v", err, errInvalidPoW)
+	}
+	// Ensure the uncle inclusion rules are enforced before verifying any seal
+	ancestor := types.NewBlockWithHeader(child(block2.Header(), 1)).WithBody(nil, []*types.Header{block1.Header()})
+	if err := failer.VerifyUncles(chain, ancestor); err != errUncleIsAncestor {
+		t.Fatalf("ancestor uncle error mismatch: have %v, want %v", err, errUncleIsAncestor)
+	}
+	duplicate := types.NewBlockWithHeader(child(block2.Header(), 1)).WithBody(nil, []*types.Header{uncle, uncle})
+	if err := valid.VerifyUncles(chain, duplicate); err != errDuplicateUncle {
+		t.Fatalf("duplicate uncle error mismatch: have %v, want %v", err, errDuplicateUncle)
+	}
+}
+
+// Tests that a seal whose PoW result satisfies the target is still rejected if
+// it carries a mix digest other than the recomputed one.
+func TestTamperedMixDigest(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1), Nonce: types.EncodeNonce(1)}
+	digest, _ := ethash.hashimoto(1, ethash.SealHash(header), header.Nonce.Uint64(), false)
+	header.MixDigest = common.BytesToHash(digest)
+	if err := ethash.VerifyOnce(header); err != nil {
+		t.Fatalf("valid seal rejected: %v", err)
+	}
+	header.MixDigest[0] ^= 0x01
+	for _, fulldag := range []bool{false, true} {
+		if err := ethash.verifySeal(nil, header, fulldag); !errors.Is(err, errInvalidMixDigest) {
+			t.Errorf("fulldag %v: error mismatch: have %v, want %v", fulldag, err, errInvalidMixDigest)
+		}
+	}
+	if err := ethash.VerifyOnce(header); !errors.Is(err, errInvalidMixDigest) {
+		t.Errorf("one-off verification error mismatch: have %v, want %v", err, errInvalidMixDigest)
+	}
+}
+
+// Tests that the seal quality of two sealed headers orders them the same way as
+// their recomputed PoW results.
+func TestSealQuality(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	var (
+		headers [2]*types.Header
+		results [2][]byte
+	)
+	for i := range headers {
+		header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1), Nonce: types.EncodeNonce(uint64(i + 1))}
+		digest, result := ethash.hashimoto(1, ethash.SealHash(header), header.Nonce.Uint64(), false)
+		header.MixDigest = common.BytesToHash(digest)
+		headers[i], results[i] = header, result
+	}
+	a, b := ethash.SealQuality(headers[0]), ethash.SealQuality(headers[1])
+	if a == nil || b == nil {
+		t.Fatalf("missing seal quality: have %v, %v", a, b)
+	}
+	if a.Cmp(new(big.Int).SetBytes(results[0])) != 0 {
+		t.Errorf("seal quality mismatch: have %x, want %x", a, results[0])
+	}
+	if have, want := a.Cmp(b), bytes.Compare(results[0], results[1]); have != want {
+		t.Errorf("seal quality ordering mismatch: have %d, want %d", have, want)
+	}
+	if q := NewFaker().SealQuality(headers[0]); q != nil {
+		t.Errorf("fake seal quality mismatch: have %v, want nil", q)
+	}
+}
+
+// Tests that seals can be verified without a chain reader, whereas the checks
+// genuinely needing chain data fail cleanly instead of panicking.
+func TestNilChain(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1), Nonce: types.EncodeNonce(1)}
+	digest, _ := ethash.hashimoto(1, ethash.SealHash(header), header.Nonce.Uint64(), false)
+	header.MixDigest = common.BytesToHash(digest)
+	for _, fulldag := range []bool{false, true} {
+		if err := ethash.verifySeal(nil, header, fulldag); err != nil {
+			t.Errorf("fulldag %v: seal rejected without chain: %v", fulldag, err)
+		}
+	}
+	if err := ethash.VerifyHeader(nil, header, true); !errors.Is(err, errMissingChain) {
+		t.Errorf("header verification error mismatch: have %v, want %v", err, errMissingChain)
+	}
+	_, results := ethash.VerifyHeaders(nil, []*types.Header{header}, []bool{true})
+	if err := <-results; !errors.Is(err, errMissingChain) {
+		t.Errorf("batch header verification error mismatch: have %v, want %v", err, errMissingChain)
+	}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2)}).WithBody(nil, []*types.Header{header})
+	if err := ethash.VerifyUncles(nil, block); !errors.Is(err, errMissingChain) {
+		t.Errorf("uncle verification error mismatch: have %v, want %v", err, errMissingChain)
+	}
+	if err := ethash.Prepare(nil, &types.Header{Number: big.NewInt(2)}); !errors.Is(err, errMissingChain) {
+		t.Errorf("prepare error mismatch: have %v, want %v", err, errMissingChain)
+	}
+}
+
+// Tests that recomputed PoW results are cross-checked against an externally
+// provided one, using the known-answer vector of the hashimoto tests.
+func TestVerifyAgainst(t *testing.T) {
+	var (
+		sealhash = common.HexToHash("0xc9149cc0386e689d789a1c2f3d5d169a61a6218ed30e74414dc736e442ef3d1f")
+		want     = hexutil.MustDecode("0xd3539235ee2e6f8db665c0a72169f55b7f6c605712330b778ec3944f0eb5a557")
+	)
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	if err := ethash.verifyAgainst(0, sealhash, 0, want); err != nil {
+		t.Fatalf("known answer rejected: %v", err)
+	}
+	bad := common.CopyBytes(want)
+	bad[5] ^= 0x01
+	bad[17] ^= 0x80
+	err := ethash.verifyAgainst(0, sealhash, 0, bad)
+	if !errors.Is(err, ErrResultMismatch) || !strings.Contains(err.Error(), "2 bytes differ, first at offset 5") {
+		t.Errorf("mismatch error mismatch: have %v", err)
+	}
+	if err := ethash.verifyAgainst(0, sealhash, 0, want[:31]); !errors.Is(err, ErrResultMismatch) {
+		t.Errorf("truncated result error mismatch: have %v, want %v", err, ErrResultMismatch)
+	}
+	// The header based variant must check the result of the header's seal hash
+	header := NewTestHeader(1, 100)
+	_, result := ethash.hashimoto(1, ethash.SealHash(header), 0, false)
+	if err := ethash.VerifyAgainst(header, result); err != nil {
+		t.Errorf("header result rejected: %v", err)
+	}
+	if err := ethash.VerifyAgainst(header, want); !errors.Is(err, ErrResultMismatch) {
+		t.Errorf("foreign result error mismatch: have %v, want %v", err, ErrResultMismatch)
+	}
+}
+
+// Tests that the configured extra keccak rounds are applied to the PoW result,
+// leaving the mix digest untouched.
+func TestExtraKeccakRounds(t *testing.T) {
+	var (
+		sealhash   = common.HexToHash("0xc9149cc0386e689d789a1c2f3d5d169a61a6218ed30e74414dc736e442ef3d1f")
+		wantDigest = common.HexToHash("0xe4073cffaef931d37117cefd9afd27ea0f1cad6a981dd2605c4a1ac97c519800")
+	)
+	tests := []struct {
+		rounds int
+		result common.Hash
+	}{
+		{0, common.HexToHash("0xd3539235ee2e6f8db665c0a72169f55b7f6c605712330b778ec3944f0eb5a557")},
+		{1, common.HexToHash("0xbb7ef97febcb0d9cf40351d65e3f4b51967d8252c39ed6663f8cdc640d4733b4")},
+	}
+	for _, tt := range tests {
+		ethash := NewTester(nil, false)
+		ethash.config.ExtraKeccakRounds = tt.rounds
+
+		digest, result := ethash.hashimoto(0, sealhash, 0, false)
+		if common.BytesToHash(digest) != wantDigest {
+			t.Errorf("rounds %d: digest mismatch: have %x, want %x", tt.rounds, digest, wantDigest)
+		}
+		if common.BytesToHash(result) != tt.result {
+			t.Errorf("rounds %d: result mismatch: have %x, want %x", tt.rounds, result, tt.result)
+		}
+		ethash.Close()
+	}
+}
+
+// Tests that the extra keccak rounds go through the configured keccak backend,
+// so that mining and verification hash them with the same implementation.
+func TestExtraKeccakRoundsBackend(t *testing.T) {
+	defer SetKeccakBackend(KeccakBackend{})
+
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.config.ExtraKeccakRounds = 3
+
+	want := ethash.extraRounds(make([]byte, common.HashLength))
+
+	var calls int32
+	SetKeccakBackend(KeccakBackend{
+		Keccak256: func(data ...[]byte) []byte {
+			atomic.AddInt32(&calls, 1)
+			return crypto.Keccak256(data...)
+		},
+	})
+	if have := ethash.extraRounds(make([]byte, common.HashLength)); !bytes.Equal(have, want) {
+		t.Errorf("result mismatch with wrapped backend: have %x, want %x", have, want)
+	}
+	if calls != 3 {
+		t.Errorf("backend calls mismatch: have %d, want 3", calls)
+	}
+}
+
+// Tests that a chain segment crossing a fork is verified with the engine in force
+// at each header, reporting the first header failing its engine.
+func TestVerifyTransition(t *testing.T) {
+	var headers []*types.Header
+	for i := int64(1); i <= 6; i++ {
+		headers = append(headers, &types.Header{Number: big.NewInt(i), Difficulty: big.NewInt(100)})
+	}
+	// Each engine rejects a header the other one is responsible for
+	if err := VerifyTransition(NewFakeFailer(5), NewFakeFailer(2), headers, 4); err != nil {
+		t.Fatalf("valid transition rejected: %v", err)
+	}
+	// A bad header on either side of the fork must be reported
+	if err := VerifyTransition(NewFakeFailer(3), NewFaker(), headers, 4); !errors.Is(err, errInvalidPoW) || !strings.Contains(err.Error(), "pre-fork header #3") {
+		t.Errorf("pre-fork anomaly mismatch: have %v", err)
+	}
+	if err := VerifyTransition(NewFaker(), NewFakeFailer(4), headers, 4); !errors.Is(err, errInvalidPoW) || !strings.Contains(err.Error(), "post-fork header #4") {
+		t.Errorf("post-fork anomaly mismatch: have %v", err)
+	}
+	// Headers without a number must be rejected instead of crashing
+	for i, bad := range []*types.Header{nil, {Difficulty: big.NewInt(100)}} {
+		segment := append(append([]*types.Header{}, headers[:2]...), bad)
+		if err := VerifyTransition(NewFaker(), NewFaker(), segment, 4); err == nil || !strings.Contains(err.Error(), "header 2") {
+			t.Errorf("test %d: numberless header error mismatch: have %v", i, err)
+		}
+	}
+}
+
+// Tests that a fake engine with several failing blocks rejects each of them and
+// accepts every other block.
+func TestFakeFailerMulti(t *testing.T) {
+	fails := map[uint64]bool{0: true, 3: true, 7: true}
+
+	ethash := NewFakeFailerMulti(0, 3, 7)
+	for i := uint64(0); i < 10; i++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(i), Difficulty: big.NewInt(100)}
+		err := ethash.VerifySeal(nil, header)
+		switch {
+		case fails[i] && err != errInvalidPoW:
+			t.Errorf("block %d: verification error mismatch: have %v, want %v", i, err, errInvalidPoW)
+		case !fails[i] && err != nil:
+			t.Errorf("block %d: unexpected verification error: %v", i, err)
+		}
+	}
+}
+
+// Tests that headers with a negative or absurdly large number are rejected with
+// a clean error instead of feeding the epoch computations.
+func TestBlockNumberOutOfRange(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(1)
+
+	for _, number := range []*big.Int{nil, big.NewInt(-1), new(big.Int).Lsh(big.NewInt(1), 100), new(big.Int).SetUint64(maxBlockNumber + 1)} {
+		header := &types.Header{Number: number, Difficulty: big.NewInt(1)}
+		if err := ethash.VerifySeal(nil, header); !errors.Is(err, ErrBlockNumberOutOfRange) {
+			t.Errorf("number %v: verification error mismatch: have %v, want %v", number, err, ErrBlockNumberOutOfRange)
+		}
+		if err := ethash.VerifyOnce(header); !errors.Is(err, ErrBlockNumberOutOfRange) {
+			t.Errorf("number %v: one-off verification error mismatch: have %v, want %v", number, err, ErrBlockNumberOutOfRange)
+		}
+		if check := ethash.checkSeal(header); check.Valid || check.Error == "" {
+			t.Errorf("number %v: seal check passed", number)
+		}
+		if number == nil {
+			continue // Blocks can't carry a nil number
+		}
+		if err := ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block), nil); !errors.Is(err, ErrBlockNumberOutOfRange) {
+			t.Errorf("number %v: seal error mismatch: have %v, want %v", number, err, ErrBlockNumberOutOfRange)
+		}
+	}
+	if _, err := (&API{ethash}).GetSeedHash(hexutil.Uint64(math.MaxUint64)); !errors.Is(err, ErrBlockNumberOutOfRange) {
+		t.Errorf("seed hash error mismatch: have %v, want %v", err, ErrBlockNumberOutOfRange)
+	}
+	// Ensure the bound is configurable
+	ethash.config.MaxBlockNumber = 10
+	header := &types.Header{Number: big.NewInt(11), Difficulty: big.NewInt(1)}
+	if err := ethash.VerifySeal(nil, header); !errors.Is(err, ErrBlockNumberOutOfRange) {
+		t.Errorf("configured bound error mismatch: have %v, want %v", err, ErrBlockNumberOutOfRange)
+	}
+}
+
+// Tests that a target ceiling relaxes seal verification to an easier boundary
+// than the one demanded by the header's difficulty.
+func TestTargetCeiling(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 200), Nonce: types.EncodeNonce(1)}
+	digest, result := ethash.hashimoto(1, ethash.SealHash(header), header.Nonce.Uint64(), false)
+	header.MixDigest = common.BytesToHash(digest)
+
+	if err := ethash.VerifySeal(nil, header); !errors.Is(err, errInvalidPoW) {
+		t.Fatalf("error mismatch without ceiling: have %v, want %v", err, errInvalidPoW)
+	}
+	ethash.config.TargetCeiling = new(big.Int).SetBytes(result)
+	if err := ethash.VerifySeal(nil, header); err != nil {
+		t.Fatalf("seal rejected with lenient ceiling: %v", err)
+	}
+	ethash.config.TargetCeiling = new(big.Int).Sub(new(big.Int).SetBytes(result), big.NewInt(1))
+	if err := ethash.VerifySeal(nil, header); !errors.Is(err, errInvalidPoW) {
+		t.Fatalf("error mismatch with strict ceiling: have %v, want %v", err, errInvalidPoW)
+	}
+}
+
+// Tests that headers without a valid difficulty are rejected cleanly instead of
+// crashing the target computation.
+func TestZeroDifficulty(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	for _, difficulty := range []*big.Int{nil, big.NewInt(0), big.NewInt(-1)} {
+		header := &types.Header{Number: big.NewInt(1), Difficulty: difficulty}
+		if target := CalcTarget(header); target.Sign() != 0 {
+			t.Errorf("difficulty %v: target mismatch: have %v, want 0", difficulty, target)
+		}
+		if err := ethash.VerifySeal(nil, header); err != errInvalidDifficulty {
+			t.Errorf("difficulty %v: verification error mismatch: have %v, want %v", difficulty, err, errInvalidDifficulty)
+		}
+		if err := ethash.Seal(nil, types.NewBlockWithHeader(header), nil, nil); err != errInvalidDifficulty {
+			t.Errorf("difficulty %v: sealing error mismatch: have %v, want %v", difficulty, err, errInvalidDifficulty)
+		}
+	}
+}
+
+// Benchmarks computing the PoW target of headers with a repeating difficulty,
+// with and without memoizing the targets.
+func BenchmarkTarget(b *testing.B) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	headers := make([]*types.Header, 4)
+	for i := range headers {
+		headers[i] = &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(int64(131072 + i))}
+	}
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			CalcTarget(headers[i%len(headers)])
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ethash.calcTarget(headers[i%len(headers)])
+		}
+	})
+}
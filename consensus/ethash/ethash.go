@@ -18,15 +18,21 @@
 package ethash
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
 	"math/big"
 	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -34,25 +40,41 @@ import (
 	"unsafe"
 
 	mmap "github.com/edsrzf/mmap-go"
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
 	"github.com/expanse-org/go-expanse/consensus"
+	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/metrics"
+	"github.com/expanse-org/go-expanse/params"
 	"github.com/expanse-org/go-expanse/rpc"
 	"github.com/hashicorp/golang-lru/simplelru"
 )
 
 var ErrInvalidDumpMagic = errors.New("invalid dump magic")
 
+// errPoWLatencyDisabled is returned when querying the PoW latency histogram
+// without having enabled recording it.
+var errPoWLatencyDisabled = errors.New("proof-of-work latency recording disabled")
+
 var (
 	// two256 is a big integer representing 2^256
 	two256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
 
 	// sharedEthash is a full instance that can be shared between multiple users.
-	sharedEthash = New(Config{"", 3, 0, false, "", 1, 0, false, ModeNormal, nil}, nil, false)
+	sharedEthash = New(Config{CachesInMem: 3, DatasetsInMem: 1, PowMode: ModeNormal}, nil, false)
 
 	// algorithmRevision is the data structure version used for file naming.
 	algorithmRevision = 23
 
+	// sealedCacheSize is the number of locally sealed work results to remember
+	// for cheap re-verification.
+	sealedCacheSize = 256
+
+	// targetCacheSize is the number of distinct difficulties whose PoW targets
+	// are memoized.
+	targetCacheSize = 16
+
 	// dumpMagic is a dataset dump header to sanity check a data dump.
 	dumpMagic = []uint32{0xbaddcafe, 0xfee1dead}
 )
@@ -152,6 +174,82 @@ func memoryMapAndGenerate(path string, size uint64, lock bool, generator func(bu
 	return memoryMap(path, lock)
 }
 
+// LoadDump reads an ethash cache or dataset dump, as written by WriteDump or by
+// the engine when storing caches and DAGs on disk, and returns its contents
+// without the header. Files not starting with the dump magic are rejected with
+// ErrInvalidDumpMagic.
+//
+// Dumps are stored in the byte order of the machine writing them. The magic
+// doubles as a byte order mark, so dumps written on a machine of the opposite
+// endianness are detected and byte swapped instead of being silently misread.
+func LoadDump(path string) ([]uint32, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	header := 4 * len(dumpMagic)
+	if len(blob) < header {
+		return nil, ErrInvalidDumpMagic
+	}
+	if len(blob)%4 != 0 {
+		return nil, fmt.Errorf("corrupt dump: size %d is not a multiple of 4 bytes", len(blob))
+	}
+	native, foreign := binary.ByteOrder(binary.LittleEndian), binary.ByteOrder(binary.BigEndian)
+	if !isLittleEndian() {
+		native, foreign = foreign, native
+	}
+	var order binary.ByteOrder
+	for _, candidate := range []binary.ByteOrder{native, foreign} {
+		if hasDumpMagic(blob, candidate) {
+			order = candidate
+			break
+		}
+	}
+	if order == nil {
+		return nil, ErrInvalidDumpMagic
+	}
+	// Fix up the byte order in place and reinterpret the read buffer, so that
+	// loading a dump needs no more memory than the file itself.
+	if order != native {
+		swap(blob)
+	}
+	var words []uint32
+	view := (*reflect.SliceHeader)(unsafe.Pointer(&words))
+	view.Data = uintptr(unsafe.Pointer(&blob[0]))
+	view.Len = len(blob) / 4
+	view.Cap = len(blob) / 4
+
+	return words[len(dumpMagic):], nil
+}
+
+// hasDumpMagic returns whether the blob starts with the dump magic when decoded
+// in the given byte order.
+func hasDumpMagic(blob []byte, order binary.ByteOrder) bool {
+	for i, magic := range dumpMagic {
+		if order.Uint32(blob[4*i:]) != magic {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteDump stores the given cache or dataset in a dump file prefixed with the
+// dump magic, which can be loaded back by LoadDump or picked up by the engine
+// if placed in its cache or DAG directory under the expected name.
+func WriteDump(path string, data []uint32) error {
+	file, mem, _, err := memoryMapAndGenerate(path, uint64(4*len(data)), false, func(buffer []uint32) {
+		copy(buffer, data)
+	})
+	if err != nil {
+		return err
+	}
+	if err := mem.Unmap(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
 // lru tracks caches or datasets by their last use time, keeping at most N of them.
 type lru struct {
 	what string
@@ -206,23 +304,24 @@ func (lru *lru) get(epoch uint64) (item, future interface{}) {
 
 // cache wraps an ethash cache with some metadata to allow easier concurrent use.
 type cache struct {
-	epoch uint64    // Epoch for which this cache is relevant
-	dump  *os.File  // File descriptor of the memory mapped cache
-	mmap  mmap.MMap // Memory map itself to unmap before releasing
-	cache []uint32  // The actual cache data content (may be memory mapped)
-	once  sync.Once // Ensures the cache is generated only once
+	epoch  uint64       // Epoch for which this cache is relevant
+	params MemoryParams // Memory parameters to size the cache with
+	dump   *os.File     // File descriptor of the memory mapped cache
+	mmap   mmap.MMap    // Memory map itself to unmap before releasing
+	cache  []uint32     // The actual cache data content (may be memory mapped)
+	once   sync.Once    // Ensures the cache is generated only once
 }
 
 // newCache creates a new ethash verification cache and returns it as a plain Go
 // interface to be usable in an LRU cache.
-func newCache(epoch uint64) interface{} {
-	return &cache{epoch: epoch}
+func newCache(epoch uint64, params MemoryParams) interface{} {
+	return &cache{epoch: epoch, params: params}
 }
 
 // generate ensures that the cache content is generated before use.
 func (c *cache) generate(dir string, limit int, lock bool, test bool) {
 	c.once.Do(func() {
-		size := cacheSize(c.epoch*epochLength + 1)
+		size := c.params.cacheSize(c.epoch*epochLength + 1)
 		seed := seedHash(c.epoch*epochLength + 1)
 		if test {
 			size = 1024
@@ -238,7 +337,7 @@ func (c *cache) generate(dir string, limit int, lock bool, test bool) {
 		if !isLittleEndian() {
 			endian = ".be"
 		}
-		path := filepath.Join(dir, fmt.Sprintf("cache-R%d-%x%s", algorithmRevision, seed[:8], endian))
+		path := filepath.Join(dir, fmt.Sprintf("cache-R%d-%x%s%s", algorithmRevision, seed[:8], c.params.tag(), endian))
 		logger := log.New("epoch", c.epoch)
 
 		// We're about to mmap the file, ensure that the mapping is cleaned up when the
@@ -265,7 +364,7 @@ func (c *cache) generate(dir string, limit int, lock bool, test bool) {
 		// Iterate over all previous instances and delete old ones
 		for ep := int(c.epoch) - limit; ep >= 0; ep-- {
 			seed := seedHash(uint64(ep)*epochLength + 1)
-			path := filepath.Join(dir, fmt.Sprintf("cache-R%d-%x%s", algorithmRevision, seed[:8], endian))
+			path := filepath.Join(dir, fmt.Sprintf("cache-R%d-%x%s%s", algorithmRevision, seed[:8], c.params.tag(), endian))
 			os.Remove(path)
 		}
 	})
@@ -282,18 +381,19 @@ func (c *cache) finalizer() {
 
 // dataset wraps an ethash dataset with some metadata to allow easier concurrent use.
 type dataset struct {
-	epoch   uint64    // Epoch for which this cache is relevant
-	dump    *os.File  // File descriptor of the memory mapped cache
-	mmap    mmap.MMap // Memory map itself to unmap before releasing
-	dataset []uint32  // The actual cache data content
-	once    sync.Once // Ensures the cache is generated only once
-	done    uint32    // Atomic flag to determine generation status
+	epoch   uint64       // Epoch for which this cache is relevant
+	params  MemoryParams // Memory parameters to size the dataset with
+	dump    *os.File     // File descriptor of the memory mapped cache
+	mmap    mmap.MMap    // Memory map itself to unmap before releasing
+	dataset []uint32     // The actual cache data content
+	once    sync.Once    // Ensures the cache is generated only once
+	done    uint32       // Atomic flag to determine generation status
 }
 
 // newDataset creates a new ethash mining dataset and returns it as a plain Go
 // interface to be usable in an LRU cache.
-func newDataset(epoch uint64) interface{} {
-	return &dataset{epoch: epoch}
+func newDataset(epoch uint64, params MemoryParams) interface{} {
+	return &dataset{epoch: epoch, params: params}
 }
 
 // generate ensures that the dataset content is generated before use.
@@ -302,8 +402,8 @@ func (d *dataset) generate(dir string, limit int, lock bool, test bool) {
 		// Mark the dataset generated after we're done. This is needed for remote
 		defer atomic.StoreUint32(&d.done, 1)
 
-		csize := cacheSize(d.epoch*epochLength + 1)
-		dsize := datasetSize(d.epoch*epochLength + 1)
+		csize := d.params.cacheSize(d.epoch*epochLength + 1)
+		dsize := d.params.datasetSize(d.epoch*epochLength + 1)
 		seed := seedHash(d.epoch*epochLength + 1)
 		if test {
 			csize = 1024
@@ -324,7 +424,7 @@ func (d *dataset) generate(dir string, limit int, lock bool, test bool) {
 		if !isLittleEndian() {
 			endian = ".be"
 		}
-		path := filepath.Join(dir, fmt.Sprintf("full-R%d-%x%s", algorithmRevision, seed[:8], endian))
+		path := filepath.Join(dir, fmt.Sprintf("full-R%d-%x%s%s", algorithmRevision, seed[:8], d.params.tag(), endian))
 		logger := log.New("epoch", d.epoch)
 
 		// We're about to mmap the file, ensure that the mapping is cleaned up when the
@@ -354,7 +454,7 @@ func (d *dataset) generate(dir string, limit int, lock bool, test bool) {
 		// Iterate over all previous instances and delete old ones
 		for ep := int(d.epoch) - limit; ep >= 0; ep-- {
 			seed := seedHash(uint64(ep)*epochLength + 1)
-			path := filepath.Join(dir, fmt.Sprintf("full-R%d-%x%s", algorithmRevision, seed[:8], endian))
+			path := filepath.Join(dir, fmt.Sprintf("full-R%d-%x%s%s", algorithmRevision, seed[:8], d.params.tag(), endian))
 			os.Remove(path)
 		}
 	})
@@ -397,6 +497,7 @@ const (
 	ModeTest
 	ModeFake
 	ModeFullFake
+	ModeSemiFake
 )
 
 // Config are the configuration parameters of the ethash.
@@ -411,7 +512,177 @@ type Config struct {
 	DatasetsLockMmap bool
 	PowMode          Mode
 
-	Log log.Logger `toml:"-"`
+	// MemoryParams overrides the sizing of the verification caches and mining
+	// datasets. The zero value selects the canonical ethash parameters. Initial
+	// sizes left unset (or too small) in a custom struct use the canonical ones.
+	MemoryParams MemoryParams
+
+	// ExtraKeccakRounds is the number of additional keccak256 passes applied to
+	// the PoW result before it is compared against the target, for research into
+	// the security margins of the PoW. This is non-standard: every node of a
+	// network must use the same value, or they will reject each other's seals.
+	// Zero is the standard ethash algorithm.
+	ExtraKeccakRounds int
+
+	// ExtraNonce, if set, is placed at the end of the extra-data of every block
+	// assembled by FinalizeAndAssemble, leaving a fixed region for pools to
+	// coordinate merged work. The original extra-data is truncated if needed to
+	// make room. An extra-nonce exceeding the extra-data limit is ignored.
+	ExtraNonce []byte
+
+	// CompactTarget makes work packages carry the target in the compact "nBits"
+	// encoding (4 bytes of hex, see CompactToTarget) instead of the full 32 bytes,
+	// for miners built on Bitcoin tooling.
+	CompactTarget bool
+
+	// ResultsBuffer is the number of sealing results buffered by the engine if
+	// the consumer is not ready to receive them, instead of dropping them. Note,
+	// buffered solutions might be stale by the time they are consumed.
+	ResultsBuffer int
+
+	// ResultSendTimeout is the time a buffered sealing result waits for the
+	// consumer to read it. If it expires, the result is logged and dropped and
+	// no further results are forwarded. Zero waits until sealing is stopped.
+	ResultSendTimeout time.Duration
+
+	// RemoteGCInterval is the interval at which the remote sealer evicts stale
+	// hash rate submissions and pending work. Zero means the default of 5s.
+	RemoteGCInterval time.Duration
+
+	// MinNotifyInterval is the minimum time between two work pushes to the notify
+	// URLs. Work arriving sooner is coalesced, and the latest work is pushed once
+	// the interval expires. Zero pushes every work package right away.
+	MinNotifyInterval time.Duration
+
+	// NotifyCleanFlag appends a "clean jobs" flag to the work packages pushed
+	// to the notify URLs, signalling whether the work obsoletes all previous
+	// work. This changes the notification from an array of 4 strings to one of
+	// 5 elements, so notify consumers must be prepared for it.
+	NotifyCleanFlag bool
+
+	// AllowedFutureBlockTime is the maximum drift a non-uncle header's timestamp
+	// may be ahead of the local clock. Zero means the default of 15s.
+	AllowedFutureBlockTime time.Duration
+
+	// DifficultyCalculators registers alternate difficulty algorithms keyed by
+	// the fork block they activate at. Each applies from its fork block until
+	// the next registered one; blocks before the first fork use the default
+	// algorithm.
+	DifficultyCalculators map[uint64]DifficultyCalculator `toml:"-" json:"-"`
+
+	// DifficultyByBlock scripts the difficulty of specific blocks, for scenario
+	// tests on deterministic testnets. CalcDifficulty returns the given difficulty
+	// for listed blocks, others use the normal calculation.
+	DifficultyByBlock map[uint64]*big.Int `toml:"-" json:"-"`
+
+	// MaxBlockNumber is the largest block number whose seal is verified or
+	// mined, guarding the epoch computations against absurd inputs. Zero means
+	// the default of 2^35.
+	MaxBlockNumber uint64
+
+	// TargetCeiling, if set, is the easiest PoW boundary seals are verified
+	// against: results below it are accepted even if the header's difficulty
+	// demands a harder target. Meant for testnets only, it must be left nil on
+	// any network whose seals need to be secure.
+	TargetCeiling *big.Int
+
+	// VerifyCacheDir, if set, is the directory to persist the seal hashes of
+	// verified headers in, skipping their proof-of-work on later verifications
+	// even across restarts. The recorded PoW results are still checked against
+	// the target. Only the most recently verified seals are kept.
+	VerifyCacheDir string
+
+	// RecordWork, if set, is the file to record the work packages pushed to the
+	// remote sealer in, along with the time they were pushed at, for replaying
+	// them via ReplayWork to load test remote miners. Any previous recording at
+	// the path is replaced.
+	RecordWork string
+
+	// MaxVerifyConcurrency is the maximum number of seals whose proof-of-work
+	// is recomputed concurrently, reserving CPU for other work during bulk
+	// imports. Zero means unbounded.
+	MaxVerifyConcurrency int
+
+	// SelfTestInterval, if set, makes the engine periodically seal a synthetic
+	// difficulty 1 header on one local mining thread and verify the result with
+	// the verification cache, counting successes and failures to catch silent
+	// breakage of the PoW path. The mining dataset of the latest epoch seen is
+	// generated if needed. Self-tests are skipped while a seal operation is in
+	// progress.
+	SelfTestInterval time.Duration
+
+	// TargetHashrate, if set, limits the local mining hash rate (hashes per
+	// second) to save power: every few seconds one mining thread is paused if
+	// the measured hash rate is above the target, or resumed if below, never
+	// exceeding the configured thread count.
+	TargetHashrate float64
+
+	// SubmitWorkBuffer and SubmitRateBuffer are the number of remote work and
+	// hash rate submissions queued up for the remote sealer before submitters
+	// start blocking. Every submitter still waits for its own submission to be
+	// processed, but a queue lets bursts be handed off without contending for
+	// the sealer. Zero means unbuffered, i.e. every hand-off waits for the
+	// sealer to pick it up. Negative values are treated as zero.
+	SubmitWorkBuffer int
+	SubmitRateBuffer int
+
+	// LazyRemoteSealer defers starting the event loop of the remote sealer until
+	// it's first used (work is pushed, fetched or submitted), if there are no
+	// notify URLs. This saves a goroutine on nodes which only verify seals.
+	LazyRemoteSealer bool
+
+	// HashrateWindow is the number of recent hash rate submissions kept for each
+	// remote miner. The miner's hash rate is the median of its window, so that a
+	// single spurious submission doesn't skew the total. Zero or one means only
+	// the last submission is used.
+	HashrateWindow int
+
+	// ObserveOnly makes seal verification log and count invalid seals, but
+	// otherwise accept them, e.g. to shadow-deploy changes to the seal rules.
+	// Remote mining solutions are still strictly verified.
+	ObserveOnly bool
+
+	// RecordPoWLatency enables recording the execution times of the proof-of-work
+	// computations done to verify seals into a histogram, exposed through the
+	// metrics registry and the API for performance regression tracking.
+	RecordPoWLatency bool
+
+	// ThreadAffinity, if set, lists the CPUs the local mining threads are pinned
+	// to, the i-th thread running on the i-th CPU (wrapping around if there are
+	// more threads than CPUs). Entries which are not valid CPU indices are
+	// dropped. It is ignored on platforms without support.
+	ThreadAffinity []int
+
+	// MineGate, if set, is consulted by the local mining threads before and
+	// periodically during the nonce search. While it returns false the search
+	// idles, allowing mining to be paused without changing the thread count.
+	MineGate func() bool `toml:"-" json:"-"`
+
+	// ProgressCallback, if set, is periodically invoked while sealing with the
+	// number of nonces tried so far by all the local mining threads together.
+	// Calls are throttled and made from a single goroutine per seal operation.
+	ProgressCallback func(hashesTried uint64) `toml:"-" json:"-"`
+
+	// RandSource, if set, is read to seed the random generator picking the
+	// starting nonces of the local mining threads, instead of crypto/rand. A
+	// deterministic source makes the nonce search reproducible, but a weak one
+	// makes distinct miners scan overlapping parts of the nonce space.
+	RandSource io.Reader `toml:"-" json:"-"`
+
+	// NotifyTransport, if set, is used by the remote sealer to deliver work
+	// notifications instead of the default HTTP transport.
+	NotifyTransport http.RoundTripper `toml:"-" json:"-"`
+
+	// Clock, if set, is the time source used for fake verification delays, the
+	// mining gate rechecks and remote hash rate expiry instead of the system
+	// clock. It is meant for tests driving the engine with a simulated clock.
+	Clock mclock.Clock `toml:"-" json:"-"`
+
+	Log log.Logger `toml:"-" json:"-"`
+
+	// LogContext holds key/value pairs attached to every log line emitted by the
+	// engine, to tell apart multiple engines running in the same process.
+	LogContext map[string]interface{} `toml:"-" json:"-"`
 }
 
 // Ethash is a consensus engine based on proof-of-work implementing the ethash
@@ -423,16 +694,44 @@ type Ethash struct {
 	datasets *lru // In memory datasets to avoid regenerating too often
 
 	// Mining related fields
-	rand     *rand.Rand    // Properly seeded random source for nonces
-	threads  int           // Number of threads to mine on if mining
-	update   chan struct{} // Notification channel to update mining parameters
-	hashrate metrics.Meter // Meter tracking the average hashrate
+	rand     *rand.Rand        // Properly seeded random source for nonces
+	threads  int               // Number of threads to mine on if mining
+	sealing  int32             // Number of seal operations in progress (atomic)
+	throttle int32             // Number of mining threads allowed to search, zero if unthrottled (atomic)
+	update   chan struct{}     // Notification channel to update mining parameters
+	hashrate metrics.Meter     // Meter tracking the average hashrate
+	observed metrics.Counter   // Counter of invalid seals accepted in observe-only mode
+	latency  metrics.Histogram // Execution times of seal verification PoW computations
+	epochs   metrics.Counter   // Counter of epoch transitions seen while sealing or verifying
+	epoch    uint64            // Highest epoch seen while sealing or verifying, plus one (atomic)
+	selfPass metrics.Counter   // Counter of successful PoW self-tests
+	selfFail metrics.Counter   // Counter of failed PoW self-tests
 	remote   *remoteSealer
+	cursors  *nonceCursors       // Nonce search progress of the most recent local seal
+	resume   *nonceCursors       // Imported nonce cursors to continue from on the next matching seal
+	lastSeal *SealInfo           // Details of the most recent locally found solution
+	sealers  *sealGroup          // Seal operations in progress, aborted together by AbortSealing
+	jobs     map[uint64]*sealJob // Seal operations in progress, keyed by job id
+	jobID    uint64              // Id of the most recently started seal job
+	tryLock  sync.Mutex          // Serialises TrySeal calls, so that at most one of them starts sealing
+	retire   chan struct{}       // Closed to retire the result forwarder of the previous seal
+
+	sealed     *simplelru.LRU // Recently sealed work, allowing re-verification without recomputing the PoW
+	sealedLock sync.Mutex     // Protects the sealed work cache
+	targets    *simplelru.LRU // Recently computed PoW targets, keyed by difficulty
+	targetLock sync.Mutex     // Protects the target cache
+	verified   *verifyCache   // On-disk record of verified seals, persisting across restarts
+	verifySem  chan struct{}  // Semaphore bounding the concurrent PoW verifications, nil if unbounded
+	events     eventBus       // Subscribers to the sealing and verification events
 
 	// The fields below are hooks for testing
-	shared    *Ethash       // Shared PoW verifier to avoid cache regeneration
-	fakeFail  uint64        // Block number which fails PoW check even in fake mode
-	fakeDelay time.Duration // Time delay to sleep for before returning from verify
+	shared     *Ethash             // Shared PoW verifier to avoid cache regeneration
+	fakeFail   uint64              // Block number which fails PoW check even in fake mode
+	fakeFails  map[uint64]struct{} // Block numbers which fail PoW check even in fake mode
+	fakeDelay  time.Duration       // Time delay to sleep for before returning from verify
+	minDiff    *big.Int            // Difficulty floor to seal blocks against, nil if disabled
+	verifyHook func()              // Invoked while holding a verification slot, before recomputing the PoW
+	clock      mclock.Clock        // Time source used for fake verification delays and remote hash rate expiry
 
 	lock      sync.Mutex // Ensures thread safety for the in-memory caches and mining fields
 	closeOnce sync.Once  // Ensures exit channel will not be closed twice.
@@ -441,14 +740,72 @@ type Ethash struct {
 // New creates a full sized ethash PoW scheme and starts a background thread for
 // remote mining, also optionally notifying a batch of remote services of new work
 // packages.
+//
+// The random generator picking the starting nonces is seeded before returning,
+// so that the first seal starts searching immediately.
 func New(config Config, notify []string, noverify bool) *Ethash {
 	if config.Log == nil {
 		config.Log = log.Root()
 	}
+	if len(config.LogContext) > 0 {
+		keys := make([]string, 0, len(config.LogContext))
+		for key := range config.LogContext {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		ctx := make([]interface{}, 0, 2*len(keys))
+		for _, key := range keys {
+			ctx = append(ctx, key, config.LogContext[key])
+		}
+		config.Log = config.Log.New(ctx...)
+	}
 	if config.CachesInMem <= 0 {
 		config.Log.Warn("One ethash cache must always be in memory", "requested", config.CachesInMem)
 		config.CachesInMem = 1
 	}
+	if config.SubmitWorkBuffer < 0 {
+		config.Log.Warn("Negative ethash work submission buffer, disabling", "requested", config.SubmitWorkBuffer)
+		config.SubmitWorkBuffer = 0
+	}
+	if config.SubmitRateBuffer < 0 {
+		config.Log.Warn("Negative ethash hash rate submission buffer, disabling", "requested", config.SubmitRateBuffer)
+		config.SubmitRateBuffer = 0
+	}
+	// Partially set memory parameters would underflow the size computations, so
+	// fall back to the canonical initial sizes if they're missing or too small
+	// to hold the two rows the size search needs.
+	if params := &config.MemoryParams; !params.canonical() {
+		if params.CacheInitBytes < 3*hashBytes {
+			config.Log.Warn("Ethash cache size too small, using default", "requested", params.CacheInitBytes, "default", DefaultMemoryParams.CacheInitBytes)
+			params.CacheInitBytes = DefaultMemoryParams.CacheInitBytes
+		}
+		if params.DatasetInitBytes < 3*mixBytes {
+			config.Log.Warn("Ethash dataset size too small, using default", "requested", params.DatasetInitBytes, "default", DefaultMemoryParams.DatasetInitBytes)
+			params.DatasetInitBytes = DefaultMemoryParams.DatasetInitBytes
+		}
+	}
+	if config.Clock == nil {
+		config.Clock = mclock.System{}
+	}
+	if len(config.ThreadAffinity) > 0 {
+		cpus := make([]int, 0, len(config.ThreadAffinity))
+		for _, cpu := range config.ThreadAffinity {
+			if cpu < 0 || cpu >= runtime.NumCPU() {
+				config.Log.Warn("Ignoring invalid ethash thread affinity", "cpu", cpu, "cpus", runtime.NumCPU())
+				continue
+			}
+			cpus = append(cpus, cpu)
+		}
+		if len(cpus) == 0 {
+			cpus = nil
+		}
+		config.ThreadAffinity = cpus
+	}
+	if len(config.ExtraNonce) > int(params.MaximumExtraDataSize) {
+		config.Log.Warn("Ethash extra-nonce exceeds extra-data limit, ignoring", "size", len(config.ExtraNonce), "limit", params.MaximumExtraDataSize)
+		config.ExtraNonce = nil
+	}
 	if config.CacheDir != "" && config.CachesOnDisk > 0 {
 		config.Log.Info("Disk storage enabled for ethash caches", "dir", config.CacheDir, "count", config.CachesOnDisk)
 	}
@@ -457,29 +814,95 @@ func New(config Config, notify []string, noverify bool) *Ethash {
 	}
 	ethash := &Ethash{
 		config:   config,
-		caches:   newlru("cache", config.CachesInMem, newCache),
-		datasets: newlru("dataset", config.DatasetsInMem, newDataset),
+		caches:   newlru("cache", config.CachesInMem, func(epoch uint64) interface{} { return newCache(epoch, config.MemoryParams) }),
+		datasets: newlru("dataset", config.DatasetsInMem, func(epoch uint64) interface{} { return newDataset(epoch, config.MemoryParams) }),
 		update:   make(chan struct{}),
 		hashrate: metrics.NewMeterForced(),
+		observed: metrics.NewCounterForced(),
+		latency:  metrics.NewHistogramForced(metrics.NewExpDecaySampleForced(1028, 0.015)),
+		epochs:   metrics.NewCounterForced(),
+		selfPass: metrics.NewCounterForced(),
+		selfFail: metrics.NewCounterForced(),
+		clock:    config.Clock,
+	}
+	ethash.sealed, _ = simplelru.NewLRU(sealedCacheSize, nil)
+	ethash.targets, _ = simplelru.NewLRU(targetCacheSize, nil)
+	if config.MaxVerifyConcurrency > 0 {
+		ethash.verifySem = make(chan struct{}, config.MaxVerifyConcurrency)
+	}
+	if config.VerifyCacheDir != "" {
+		verified, err := openVerifyCache(config.VerifyCacheDir, config.PowMode, config.ExtraKeccakRounds, config.MemoryParams, config.Log)
+		if err != nil {
+			config.Log.Warn("Failed to open seal verification cache", "dir", config.VerifyCacheDir, "err", err)
+		} else {
+			ethash.verified = verified
+		}
+	}
+	// Seed the nonce generator right away, so the first seal doesn't wait on it
+	ethash.lock.Lock()
+	if err := ethash.seedRand(); err != nil {
+		config.Log.Warn("Failed to seed nonce generator, deferring to first seal", "err", err)
 	}
+	ethash.lock.Unlock()
+
 	ethash.remote = startRemoteSealer(ethash, notify, noverify)
+	if config.SelfTestInterval > 0 {
+		go ethash.selfTestLoop(config.SelfTestInterval)
+	}
+	if config.TargetHashrate > 0 {
+		go ethash.throttleLoop(throttleInterval)
+	}
 	return ethash
 }
 
 // NewTester creates a small sized ethash PoW scheme useful only for testing
 // purposes.
 func NewTester(notify []string, noverify bool) *Ethash {
+	return NewTesterWithDifficulty(notify, noverify, nil)
+}
+
+// NewTesterWithDifficulty creates a small sized ethash PoW scheme useful only for
+// testing purposes, which seals blocks against at least the given difficulty.
+// Sealing easier blocks is thus artificially slowed down, whilst the results are
+// still valid for the blocks' own difficulty. A nil minDiff disables the floor.
+func NewTesterWithDifficulty(notify []string, noverify bool, minDiff *big.Int) *Ethash {
 	ethash := &Ethash{
 		config:   Config{PowMode: ModeTest, Log: log.Root()},
-		caches:   newlru("cache", 1, newCache),
-		datasets: newlru("dataset", 1, newDataset),
+		caches:   newlru("cache", 1, func(epoch uint64) interface{} { return newCache(epoch, MemoryParams{}) }),
+		datasets: newlru("dataset", 1, func(epoch uint64) interface{} { return newDataset(epoch, MemoryParams{}) }),
 		update:   make(chan struct{}),
 		hashrate: metrics.NewMeterForced(),
+		observed: metrics.NewCounterForced(),
+		latency:  metrics.NewHistogramForced(metrics.NewExpDecaySampleForced(1028, 0.015)),
+		epochs:   metrics.NewCounterForced(),
+		selfPass: metrics.NewCounterForced(),
+		selfFail: metrics.NewCounterForced(),
+		clock:    mclock.System{},
+		minDiff:  minDiff,
 	}
+	ethash.sealed, _ = simplelru.NewLRU(sealedCacheSize, nil)
+	ethash.targets, _ = simplelru.NewLRU(targetCacheSize, nil)
 	ethash.remote = startRemoteSealer(ethash, notify, noverify)
 	return ethash
 }
 
+// NewTestHeader creates a minimal header at the given number and difficulty,
+// ready to be sealed and verified by a tester engine. Fields that feed into the
+// seal hash are set to the values of an empty block, so that the header doesn't
+// need any further filling in.
+func NewTestHeader(number, difficulty int64) *types.Header {
+	return &types.Header{
+		UncleHash:   types.EmptyUncleHash,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(difficulty),
+		Number:      big.NewInt(number),
+		GasLimit:    params.GenesisGasLimit,
+		Time:        uint64(number),
+		Extra:       []byte{},
+	}
+}
+
 // NewFaker creates a ethash consensus engine with a fake PoW scheme that accepts
 // all blocks' seal as valid, though they still have to conform to the Ethereum
 // consensus rules.
@@ -489,6 +912,7 @@ func NewFaker() *Ethash {
 			PowMode: ModeFake,
 			Log:     log.Root(),
 		},
+		clock: mclock.System{},
 	}
 }
 
@@ -501,10 +925,30 @@ func NewFakeFailer(fail uint64) *Ethash {
 			PowMode: ModeFake,
 			Log:     log.Root(),
 		},
+		clock:    mclock.System{},
 		fakeFail: fail,
 	}
 }
 
+// NewFakeFailerMulti creates a ethash consensus engine with a fake PoW scheme
+// that accepts all blocks as valid apart from the ones specified, though they
+// still have to conform to the Ethereum consensus rules.
+func NewFakeFailerMulti(fails ...uint64) *Ethash {
+	set := make(map[uint64]struct{}, len(fails))
+	for _, fail := range fails {
+		set[fail] = struct{}{}
+	}
+	return &Ethash{
+		config: Config{
+			PowMode: ModeFake,
+			Log:     log.Root(),
+		},
+		clock:     mclock.System{},
+		fakeFail:  math.MaxUint64,
+		fakeFails: set,
+	}
+}
+
 // NewFakeDelayer creates a ethash consensus engine with a fake PoW scheme that
 // accepts all blocks as valid, but delays verifications by some time, though
 // they still have to conform to the Ethereum consensus rules.
@@ -514,6 +958,7 @@ func NewFakeDelayer(delay time.Duration) *Ethash {
 			PowMode: ModeFake,
 			Log:     log.Root(),
 		},
+		clock:     mclock.System{},
 		fakeDelay: delay,
 	}
 }
@@ -526,6 +971,20 @@ func NewFullFaker() *Ethash {
 			PowMode: ModeFullFake,
 			Log:     log.Root(),
 		},
+		clock: mclock.System{},
+	}
+}
+
+// NewSemiFaker creates an ethash consensus engine with a fake scheme that accepts
+// all blocks' seals and uncles as valid, but still checks that their difficulty
+// conforms to the Ethereum consensus rules. Other header fields are not checked.
+func NewSemiFaker() *Ethash {
+	return &Ethash{
+		config: Config{
+			PowMode: ModeSemiFake,
+			Log:     log.Root(),
+		},
+		clock: mclock.System{},
 	}
 }
 
@@ -535,14 +994,26 @@ func NewShared() *Ethash {
 	return &Ethash{shared: sharedEthash}
 }
 
-// Close closes the exit channel to notify all backend threads exiting.
+// Close closes the exit channel to notify all backend threads exiting, aborting
+// any seal operations still in progress.
 func (ethash *Ethash) Close() error {
 	var err error
 	ethash.closeOnce.Do(func() {
+		// Stop any local mining still in progress, its threads would otherwise
+		// keep searching (and pinning the dataset) after the engine is gone.
+		// Shared engines leave the shared instance's seals alone.
+		if ethash.shared == nil {
+			ethash.AbortSealing()
+		}
+
+		if ethash.verified != nil {
+			err = ethash.verified.close()
+		}
 		// Short circuit if the exit channel is not allocated.
 		if ethash.remote == nil {
 			return
 		}
+		ethash.remote.start()
 		close(ethash.remote.requestExit)
 		<-ethash.remote.exitCh
 	})
@@ -568,6 +1039,26 @@ func (ethash *Ethash) cache(block uint64) *cache {
 	return current
 }
 
+// noteEpoch records the epoch of a block being sealed or verified. The first
+// time the block number crosses into a later epoch than any seen before, the
+// epoch transition is counted and logged. Blocks of earlier epochs are ignored.
+func (ethash *Ethash) noteEpoch(block uint64) {
+	epoch := block / epochLength
+	for {
+		last := atomic.LoadUint64(&ethash.epoch)
+		if last > epoch {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&ethash.epoch, last, epoch+1) {
+			if last > 0 && ethash.epochs != nil {
+				ethash.epochs.Inc(1)
+				ethash.config.Log.Info("Ethash epoch changed", "epoch", epoch, "number", block)
+			}
+			return
+		}
+	}
+}
+
 // dataset tries to retrieve a mining dataset for the specified block number
 // by first checking against a list of in-memory datasets, then against DAGs
 // stored on disk, and finally generating one if none can be found.
@@ -611,6 +1102,237 @@ func (ethash *Ethash) Threads() int {
 	return ethash.threads
 }
 
+// ActiveThreads returns the number of local mining threads allowed to search for
+// nonces, which is below the configured thread count while throttled to meet the
+// target hash rate.
+func (ethash *Ethash) ActiveThreads() int {
+	if ethash.shared != nil {
+		return ethash.shared.ActiveThreads()
+	}
+	limit := ethash.Threads()
+	if limit < 0 {
+		return 0
+	}
+	if limit == 0 {
+		limit = runtime.NumCPU()
+	}
+	if active := int(atomic.LoadInt32(&ethash.throttle)); active > 0 && active < limit {
+		return active
+	}
+	return limit
+}
+
+// MiningState describes how the local mining threads are configured.
+type MiningState uint
+
+const (
+	MiningIdle     MiningState = iota // Negative thread count, local mining disabled
+	MiningAllCores                    // Zero thread count, one thread per CPU core
+	MiningFixed                       // Positive thread count, mining on that many threads
+)
+
+// String implements fmt.Stringer.
+func (s MiningState) String() string {
+	switch s {
+	case MiningIdle:
+		return "idle"
+	case MiningAllCores:
+		return "all cores"
+	case MiningFixed:
+		return "fixed"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint(s))
+	}
+}
+
+// MiningState returns how the local mining threads are configured, along with
+// the number of threads a seal operation would start: zero when idle, the number
+// of CPUs when using all cores, or the configured count otherwise.
+func (ethash *Ethash) MiningState() (MiningState, int) {
+	if ethash.shared != nil {
+		return ethash.shared.MiningState()
+	}
+	threads := ethash.Threads()
+	switch {
+	case threads < 0:
+		return MiningIdle, 0
+	case threads == 0:
+		return MiningAllCores, runtime.NumCPU()
+	default:
+		return MiningFixed, threads
+	}
+}
+
+// IsSealing returns whether a seal operation is currently in progress, i.e. the
+// engine is still searching for a nonce, neither having found one nor having
+// been aborted.
+func (ethash *Ethash) IsSealing() bool {
+	if ethash.shared != nil {
+		return ethash.shared.IsSealing()
+	}
+	return atomic.LoadInt32(&ethash.sealing) > 0
+}
+
+// sealGroup tracks a set of seal operations which can be aborted together.
+type sealGroup struct {
+	abort chan struct{}  // Closed to abort all seal operations of the group
+	pend  sync.WaitGroup // Seal operations of the group still running
+}
+
+// AbortSealing stops all seal operations currently in progress, as if each one's
+// stop channel was closed, and waits until their mining threads have terminated.
+// Seal operations started afterwards are not affected.
+func (ethash *Ethash) AbortSealing() {
+	if ethash.shared != nil {
+		ethash.shared.AbortSealing()
+		return
+	}
+	ethash.lock.Lock()
+	sealers := ethash.sealers
+	ethash.sealers = nil
+	ethash.lock.Unlock()
+
+	if sealers != nil {
+		close(sealers.abort)
+		sealers.pend.Wait()
+	}
+}
+
+// SealJob describes a seal operation in progress.
+type SealJob struct {
+	ID       uint64      `json:"id"`       // Identifier of the seal operation
+	SealHash common.Hash `json:"sealHash"` // Hash of the header being sealed
+	Number   uint64      `json:"number"`   // Number of the block being sealed
+	Started  time.Time   `json:"started"`  // Time the seal operation started
+}
+
+// sealJob tracks a seal operation in progress, allowing it to be cancelled on
+// its own.
+type sealJob struct {
+	SealJob
+	cancel chan struct{} // Closed to abort the seal operation
+}
+
+// startJob registers a new seal job for the given header.
+func (ethash *Ethash) startJob(sealhash common.Hash, number uint64) *sealJob {
+	ethash.lock.Lock()
+	defer ethash.lock.Unlock()
+
+	if ethash.jobs == nil {
+		ethash.jobs = make(map[uint64]*sealJob)
+	}
+	ethash.jobID++
+	job := &sealJob{
+		SealJob: SealJob{ID: ethash.jobID, SealHash: sealhash, Number: number, Started: time.Now()},
+		cancel:  make(chan struct{}),
+	}
+	ethash.jobs[job.ID] = job
+	return job
+}
+
+// endJob unregisters a terminated seal job.
+func (ethash *Ethash) endJob(job *sealJob) {
+	ethash.lock.Lock()
+	defer ethash.lock.Unlock()
+
+	if ethash.jobs[job.ID] == job {
+		delete(ethash.jobs, job.ID)
+	}
+}
+
+// ListJobs returns the seal operations currently in progress, ordered by id.
+func (ethash *Ethash) ListJobs() []SealJob {
+	if ethash.shared != nil {
+		return ethash.shared.ListJobs()
+	}
+	ethash.lock.Lock()
+	defer ethash.lock.Unlock()
+
+	jobs := make([]SealJob, 0, len(ethash.jobs))
+	for _, job := range ethash.jobs {
+		jobs = append(jobs, job.SealJob)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// CancelJob stops the seal operation with the given id, as if its stop channel
+// was closed, leaving any other seal operations running. ErrUnknownJob is
+// returned if no such seal operation is in progress.
+func (ethash *Ethash) CancelJob(id uint64) error {
+	if ethash.shared != nil {
+		return ethash.shared.CancelJob(id)
+	}
+	ethash.lock.Lock()
+	defer ethash.lock.Unlock()
+
+	job, ok := ethash.jobs[id]
+	if !ok {
+		return ErrUnknownJob
+	}
+	delete(ethash.jobs, id)
+	close(job.cancel)
+	return nil
+}
+
+// SealInfo contains the details of a solution found by the local miner.
+type SealInfo struct {
+	SealHash  common.Hash      `json:"sealHash"`  // Hash of the sealed header without the seal fields
+	Nonce     types.BlockNonce `json:"nonce"`     // Nonce of the solution
+	MixDigest common.Hash      `json:"mixDigest"` // Mix digest of the solution
+	Time      time.Time        `json:"timestamp"` // Time the solution was found
+}
+
+// LastSeal returns the details of the most recent solution found by the local
+// miner, or ErrNoLocalSeal if none was found yet.
+func (ethash *Ethash) LastSeal() (*SealInfo, error) {
+	if ethash.shared != nil {
+		return ethash.shared.LastSeal()
+	}
+	ethash.lock.Lock()
+	defer ethash.lock.Unlock()
+
+	if ethash.lastSeal == nil {
+		return nil, ErrNoLocalSeal
+	}
+	info := *ethash.lastSeal
+	return &info, nil
+}
+
+// recordSeal stores the details of a solution found by the local miner.
+func (ethash *Ethash) recordSeal(sealhash common.Hash, header *types.Header) {
+	ethash.lock.Lock()
+	defer ethash.lock.Unlock()
+
+	ethash.lastSeal = &SealInfo{
+		SealHash:  sealhash,
+		Nonce:     header.Nonce,
+		MixDigest: header.MixDigest,
+		Time:      time.Now(),
+	}
+}
+
+// EffectiveConfig returns the configuration and the number of local mining
+// threads actually in force, resolving the delegation of shared engines to the
+// shared instance. A thread count of zero (all cores) is resolved to the number
+// of CPUs, a negative one (local mining disabled) to zero.
+func (ethash *Ethash) EffectiveConfig() (Config, int) {
+	if ethash.shared != nil {
+		return ethash.shared.EffectiveConfig()
+	}
+	ethash.lock.Lock()
+	config, threads := ethash.config, ethash.threads
+	ethash.lock.Unlock()
+
+	if threads == 0 {
+		threads = runtime.NumCPU()
+	}
+	if threads < 0 {
+		threads = 0
+	}
+	return config, threads
+}
+
 // SetThreads updates the number of mining threads currently enabled. Calling
 // this method does not start mining, only sets the thread count. If zero is
 // specified, the miner will use all cores of the machine. Setting a thread
@@ -644,6 +1366,7 @@ func (ethash *Ethash) Hashrate() float64 {
 	}
 	var res = make(chan uint64, 1)
 
+	ethash.remote.start()
 	select {
 	case ethash.remote.fetchRateCh <- res:
 	case <-ethash.remote.exitCh:
@@ -655,6 +1378,278 @@ func (ethash *Ethash) Hashrate() float64 {
 	return ethash.hashrate.Rate1() + float64(<-res)
 }
 
+// HashrateUnit is a unit hash rates can be reported in, expressed as the number
+// of hashes per second it stands for.
+type HashrateUnit uint64
+
+const (
+	HashPerSecond     HashrateUnit = 1
+	KiloHashPerSecond HashrateUnit = 1000 * HashPerSecond
+	MegaHashPerSecond HashrateUnit = 1000 * KiloHashPerSecond
+	GigaHashPerSecond HashrateUnit = 1000 * MegaHashPerSecond
+)
+
+// HashrateIn returns the same total hash rate as Hashrate, converted to the given
+// unit. A zero unit is treated as hashes per second.
+func (ethash *Ethash) HashrateIn(unit HashrateUnit) float64 {
+	if unit == 0 {
+		unit = HashPerSecond
+	}
+	return ethash.Hashrate() / float64(unit)
+}
+
+// HashrateSnapshot is a point in time view of the local hashrate meter.
+type HashrateSnapshot struct {
+	Count int64   `json:"count"` // Total number of hashes computed by the local miner
+	Mean  float64 `json:"mean"`  // Mean hashes per second since the engine was created
+}
+
+// HashrateSnapshot returns the raw totals of the local hashrate meter, without
+// the one minute moving average Hashrate is based on. Remote miners are not
+// included. The count includes every hash marked so far, whereas the mean rate
+// is only refreshed on the meter's periodic tick.
+func (ethash *Ethash) HashrateSnapshot() HashrateSnapshot {
+	if ethash.shared != nil {
+		return ethash.shared.HashrateSnapshot()
+	}
+	if ethash.hashrate == nil {
+		return HashrateSnapshot{}
+	}
+	// Count folds any pending marks into the meter, a plain snapshot would not
+	count := ethash.hashrate.Count()
+	return HashrateSnapshot{Count: count, Mean: ethash.hashrate.RateMean()}
+}
+
+// MetricsRegistry returns a registry holding the metrics of this engine: the
+// local hashrate meter, the counter of invalid seals accepted in observe-only
+// mode, the PoW latency histogram if recorded, the epoch transition counter, the
+// self-test counters if enabled and the accepted, rejected and stale remote work
+// submission counters. It is not a Prometheus collector itself, but it can be
+// served in Prometheus format with metrics/prometheus.Handler.
+func (ethash *Ethash) MetricsRegistry() metrics.Registry {
+	if ethash.shared != nil {
+		return ethash.shared.MetricsRegistry()
+	}
+	reg := metrics.NewRegistry()
+	if ethash.hashrate != nil {
+		reg.Register("ethash/hashrate", ethash.hashrate)
+	}
+	if ethash.observed != nil {
+		reg.Register("ethash/verify/observed", ethash.observed)
+	}
+	if ethash.latency != nil && ethash.config.RecordPoWLatency {
+		reg.Register("ethash/verify/latency", ethash.latency)
+	}
+	if ethash.epochs != nil {
+		reg.Register("ethash/epoch/changes", ethash.epochs)
+	}
+	if ethash.config.SelfTestInterval > 0 {
+		reg.Register("ethash/selftest/success", ethash.selfPass)
+		reg.Register("ethash/selftest/failure", ethash.selfFail)
+	}
+	if ethash.remote != nil {
+		reg.Register("ethash/remote/accepted", ethash.remote.accepted)
+		reg.Register("ethash/remote/rejected", ethash.remote.rejected)
+		reg.Register("ethash/remote/stale", ethash.remote.stale)
+	}
+	return reg
+}
+
+// PoWLatency summarises the execution times of the proof-of-work computations
+// done to verify seals.
+type PoWLatency struct {
+	Samples int64         `json:"samples"` // Number of computations recorded
+	Mean    time.Duration `json:"mean"`    // Mean execution time
+	P50     time.Duration `json:"p50"`     // Median execution time
+	P90     time.Duration `json:"p90"`     // 90th percentile execution time
+	P99     time.Duration `json:"p99"`     // 99th percentile execution time
+	Max     time.Duration `json:"max"`     // Maximum execution time
+}
+
+// PoWLatency returns the percentiles of the proof-of-work execution times
+// recorded while verifying seals, or an error if recording is disabled.
+func (ethash *Ethash) PoWLatency() (PoWLatency, error) {
+	if ethash.shared != nil {
+		return ethash.shared.PoWLatency()
+	}
+	if ethash.latency == nil || !ethash.config.RecordPoWLatency {
+		return PoWLatency{}, errPoWLatencyDisabled
+	}
+	snapshot := ethash.latency.Snapshot()
+	ps := snapshot.Percentiles([]float64{0.5, 0.9, 0.99})
+	return PoWLatency{
+		Samples: snapshot.Count(),
+		Mean:    time.Duration(snapshot.Mean()),
+		P50:     time.Duration(ps[0]),
+		P90:     time.Duration(ps[1]),
+		P99:     time.Duration(ps[2]),
+		Max:     time.Duration(snapshot.Max()),
+	}, nil
+}
+
+// SubmitWork submits a PoW solution for a work package handed out by the remote
+// sealer, returning an error if it was not accepted. Malformed submissions are
+// rejected with ErrInvalidSubmission without reaching the sealer.
+func (ethash *Ethash) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash) error {
+	return ethash.submitWork(nil, nonce, hash, digest)
+}
+
+// SubmitWorkFor submits a PoW solution just like SubmitWork, but additionally
+// requires the work package to be for the given block number, rejecting the
+// solution with ErrWorkNumberMismatch otherwise.
+func (ethash *Ethash) SubmitWorkFor(number uint64, nonce types.BlockNonce, hash, digest common.Hash) error {
+	return ethash.submitWork(&number, nonce, hash, digest)
+}
+
+// submitWork hands a PoW solution to the remote sealer, optionally along with the
+// block number the solution claims to be for.
+func (ethash *Ethash) submitWork(number *uint64, nonce types.BlockNonce, hash, digest common.Hash) error {
+	if ethash.remote == nil {
+		return errors.New("not supported")
+	}
+	if hash == (common.Hash{}) {
+		return fmt.Errorf("%w: empty seal hash", ErrInvalidSubmission)
+	}
+
+	var errc = make(chan error, 1)
+	ethash.remote.start()
+	select {
+	case ethash.remote.submitWorkCh <- &mineResult{
+		number:    number,
+		nonce:     nonce,
+		mixDigest: digest,
+		hash:      hash,
+		errc:      errc,
+	}:
+	case <-ethash.remote.exitCh:
+		return errEthashStopped
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-ethash.remote.exitCh:
+		return errEthashStopped
+	}
+}
+
+// PingSubmit sends a probe submission through the remote sealer, returning the
+// time it took to be processed and the reason it was rejected. The probe skips
+// the proof-of-work verification, so it only measures the round trip through
+// the sealer. It is never accepted, nor counted as a share.
+func (ethash *Ethash) PingSubmit() (time.Duration, error) {
+	if ethash.remote == nil {
+		return 0, errors.New("not supported")
+	}
+	var (
+		errc  = make(chan error, 1)
+		start = time.Now()
+	)
+	ethash.remote.start()
+	select {
+	case ethash.remote.submitWorkCh <- &mineResult{probe: true, errc: errc}:
+	case <-ethash.remote.exitCh:
+		return 0, errEthashStopped
+	}
+	select {
+	case err := <-errc:
+		return time.Since(start), err
+	case <-ethash.remote.exitCh:
+		return 0, errEthashStopped
+	}
+}
+
+// WorkEpoch returns the number of work packages pushed to remote miners which
+// obsoleted all previous work, i.e. were building on a new parent. Remote miners
+// can poll it to cheaply detect their work becoming stale.
+func (ethash *Ethash) WorkEpoch() uint64 {
+	if ethash.remote == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&ethash.remote.workEpoch)
+}
+
+// ShareStats returns the number of valid, stale and invalid remote work
+// submissions since startup or the last reset, optionally starting a new count
+// afterwards. The submission metrics are not affected by resets.
+func (ethash *Ethash) ShareStats(reset bool) (ShareStats, error) {
+	if ethash.remote == nil {
+		return ShareStats{}, errors.New("not supported")
+	}
+	req := &statsRequest{reset: reset, res: make(chan ShareStats, 1)}
+	ethash.remote.start()
+	select {
+	case ethash.remote.fetchStatsCh <- req:
+	case <-ethash.remote.exitCh:
+		return ShareStats{}, errEthashStopped
+	}
+	return <-req.res, nil
+}
+
+// NotifyURLs returns the endpoints notified of new work packages.
+func (ethash *Ethash) NotifyURLs() []string {
+	if ethash.remote == nil {
+		return nil
+	}
+	ethash.remote.notifyLock.RLock()
+	defer ethash.remote.notifyLock.RUnlock()
+
+	return append([]string{}, ethash.remote.notifyURLs...)
+}
+
+// SetNotifyEnabled enables or disables pushing new work packages to the notify
+// URLs, without affecting local sealing or remote miners polling for work.
+// Notifications are enabled by default.
+func (ethash *Ethash) SetNotifyEnabled(enabled bool) error {
+	if ethash.remote == nil {
+		return errors.New("not supported")
+	}
+	ethash.remote.notifyLock.Lock()
+	defer ethash.remote.notifyLock.Unlock()
+
+	ethash.remote.notifyPaused = !enabled
+	return nil
+}
+
+// SetNotifyURLs replaces the endpoints notified of new work packages. Work pushed
+// afterwards is only delivered to the new set. Every endpoint must be an absolute
+// HTTP(S) URL, otherwise the list is left unchanged.
+func (ethash *Ethash) SetNotifyURLs(urls []string) error {
+	if ethash.remote == nil {
+		return errors.New("not supported")
+	}
+	for _, rawurl := range urls {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return fmt.Errorf("invalid notify URL %q: %v", rawurl, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid notify URL %q: not an absolute HTTP(S) URL", rawurl)
+		}
+	}
+	ethash.remote.notifyLock.Lock()
+	defer ethash.remote.notifyLock.Unlock()
+
+	ethash.remote.notifyURLs = append([]string{}, urls...)
+	return nil
+}
+
+// RemoteMiners returns the remote sealers which have recently submitted their
+// hash rate, ordered by identifier.
+func (ethash *Ethash) RemoteMiners() []RemoteMinerInfo {
+	if ethash.remote == nil {
+		return nil
+	}
+	var res = make(chan []RemoteMinerInfo, 1)
+
+	ethash.remote.start()
+	select {
+	case ethash.remote.fetchMinerCh <- res:
+	case <-ethash.remote.exitCh:
+		return nil
+	}
+	return <-res
+}
+
 // APIs implements consensus.Engine, returning the user facing RPC APIs.
 func (ethash *Ethash) APIs(chain consensus.ChainHeaderReader) []rpc.API {
 	// In order to ensure backward compatibility, we exposes ethash RPC APIs
@@ -672,6 +1667,12 @@ func (ethash *Ethash) APIs(chain consensus.ChainHeaderReader) []rpc.API {
 			Service:   &API{ethash},
 			Public:    true,
 		},
+		{
+			Namespace: "miner",
+			Version:   "1.0",
+			Service:   &PrivateAPI{ethash},
+			Public:    false,
+		},
 	}
 }
 
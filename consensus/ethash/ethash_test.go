@@ -17,17 +17,32 @@
 package ethash
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/mclock"
 	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/metrics/prometheus"
 )
 
 // Tests that ethash works correctly in test mode.
@@ -54,6 +69,214 @@ func TestTestMode(t *testing.T) {
 	}
 }
 
+// Tests that the exported test header helper produces a header that seals and
+// verifies without any further setup.
+func TestNewTestHeader(t *testing.T) {
+	header := NewTestHeader(1, 100)
+	if header.UncleHash != types.EmptyUncleHash || header.TxHash != types.EmptyRootHash {
+		t.Fatalf("header not empty: uncles %x, txs %x", header.UncleHash, header.TxHash)
+	}
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	results := make(chan *types.Block)
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	select {
+	case block := <-results:
+		if err := ethash.VerifySeal(nil, block.Header()); err != nil {
+			t.Fatalf("unexpected verification error: %v", err)
+		}
+	case <-time.NewTimer(2 * time.Second).C:
+		t.Error("sealing result timeout")
+	}
+}
+
+// Tests that seals produced by the engine itself are verified without the PoW
+// being recomputed, while foreign seals still go through the full check.
+func TestSealedVerification(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	results := make(chan *types.Block)
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	var sealed *types.Header
+	select {
+	case block := <-results:
+		sealed = block.Header()
+	case <-time.NewTimer(2 * time.Second).C:
+		t.Fatal("sealing result timeout")
+	}
+	// Both the light and the full DAG verification must accept the local seal
+	for _, fulldag := range []bool{false, true} {
+		if err := ethash.verifySeal(nil, sealed, fulldag); err != nil {
+			t.Fatalf("fulldag %v: unexpected verification error: %v", fulldag, err)
+		}
+	}
+	// Corrupt the remembered result, ensuring it's the one being checked
+	sealhash := ethash.SealHash(sealed)
+	ethash.rememberSeal(sealhash, sealed.Nonce, sealed.MixDigest, bytes.Repeat([]byte{0xff}, common.HashLength))
+	if err := ethash.VerifySeal(nil, sealed); err != errInvalidPoW {
+		t.Fatalf("trusted verification error mismatch: have %v, want %v", err, errInvalidPoW)
+	}
+	// A different mix digest is not trusted and must be fully verified
+	forged := types.CopyHeader(sealed)
+	forged.MixDigest = common.HexToHash("deadbeef")
+	if err := ethash.VerifySeal(nil, forged); err != errInvalidMixDigest {
+		t.Fatalf("untrusted verification error mismatch: have %v, want %v", err, errInvalidMixDigest)
+	}
+}
+
+// Tests that the fake verification delay is driven by the configured clock.
+func TestFakeDelayClock(t *testing.T) {
+	clock := new(mclock.Simulated)
+	ethash := New(Config{PowMode: ModeFake, Clock: clock}, nil, false)
+	defer ethash.Close()
+	ethash.fakeDelay = time.Hour
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ethash.VerifySeal(nil, &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)})
+	}()
+	clock.WaitForTimers(1)
+
+	clock.Run(time.Hour - time.Second)
+	select {
+	case err := <-done:
+		t.Fatalf("verification returned before the delay elapsed: %v", err)
+	default:
+	}
+	clock.Run(time.Second)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected verification error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("verification did not return after the delay elapsed")
+	}
+}
+
+// Tests that seal verifications exceeding the timeout are abandoned with a
+// timeout error, whereas the ones completing in time return their result.
+func TestVerifySealTimeout(t *testing.T) {
+	ethash := NewFakeDelayer(time.Second)
+	defer ethash.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	start := time.Now()
+	if err := ethash.VerifySealTimeout(nil, header, 50*time.Millisecond); !errors.Is(err, ErrVerifyTimeout) {
+		t.Fatalf("slow verification error mismatch: have %v, want %v", err, ErrVerifyTimeout)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("timed out verification returned too late: %v", elapsed)
+	}
+	if err := ethash.VerifySealTimeout(nil, header, 5*time.Second); err != nil {
+		t.Fatalf("unexpected verification error: %v", err)
+	}
+}
+
+// Tests that the configured log context is attached to the engine's log lines.
+func TestLogContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.SetHandler(log.StreamHandler(&buf, log.LogfmtFormat()))
+
+	// A non-positive cache count is logged as a warning upon construction
+	ethash := New(Config{PowMode: ModeTest, Log: logger, LogContext: map[string]interface{}{"engine": "secondary"}}, nil, false)
+	defer ethash.Close()
+
+	if !strings.Contains(buf.String(), "engine=secondary") {
+		t.Fatalf("log context missing from output: %s", buf.String())
+	}
+}
+
+// Tests that creating and closing engines doesn't leak goroutines, even if a
+// seal operation is still in progress when the engine is closed.
+func TestCloseLeak(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 200)}
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		ethash := NewTester(nil, false)
+		ethash.SetThreads(2)
+		if err := ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block), nil); err != nil {
+			t.Fatalf("failed to seal block: %v", err)
+		}
+		ethash.Close()
+
+		New(Config{PowMode: ModeTest, CachesInMem: 1}, nil, false).Close()
+	}
+	for deadline := time.Now().Add(3 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		after := runtime.NumGoroutine()
+		if after <= before {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked: have %d, want at most %d", after, before)
+		}
+	}
+}
+
+// Tests that the nonce generator is seeded on construction instead of on the
+// first seal, but left alone for shared engines which delegate sealing.
+func TestEagerRandSeed(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, CachesInMem: 1}, nil, false)
+	defer ethash.Close()
+
+	ethash.lock.Lock()
+	seeded := ethash.rand != nil
+	ethash.lock.Unlock()
+	if !seeded {
+		t.Errorf("nonce generator not seeded by constructor")
+	}
+	if shared := NewShared(); shared.rand != nil {
+		t.Errorf("shared engine seeded its own nonce generator")
+	}
+}
+
+// Tests that a lazy remote sealer without notify URLs doesn't run any goroutine
+// until first used, while still serving remote work afterwards.
+func TestLazyRemoteSealer(t *testing.T) {
+	// An unused lazy sealer must not start, nor hang on close
+	New(Config{PowMode: ModeTest, CachesInMem: 1, LazyRemoteSealer: true}, nil, false).Close()
+
+	before := runtime.NumGoroutine()
+	ethash := New(Config{PowMode: ModeTest, CachesInMem: 1, LazyRemoteSealer: true}, nil, true)
+	defer ethash.Close()
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("goroutines started: have %d, want at most %d", after, before)
+	}
+	api := &API{ethash}
+	if _, err := api.GetWork(); !errors.Is(err, ErrNoMiningWork) {
+		t.Fatalf("idle work error mismatch: have %v, want %v", err, ErrNoMiningWork)
+	}
+	ethash.SetThreads(-1)
+	header := NewTestHeader(1, 100)
+	results := make(chan *types.Block, 1)
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	work, err := api.GetWork()
+	if err != nil {
+		t.Fatalf("failed to get work: %v", err)
+	}
+	if work[0] != ethash.SealHash(header).Hex() {
+		t.Errorf("work hash mismatch: have %s, want %s", work[0], ethash.SealHash(header).Hex())
+	}
+	if !api.SubmitWork(types.EncodeNonce(1), ethash.SealHash(header), common.Hash{}) {
+		t.Fatal("solution rejected")
+	}
+	if block := <-results; block.Nonce() != 1 {
+		t.Errorf("delivered nonce mismatch: have %d, want 1", block.Nonce())
+	}
+}
+
 // This test checks that cache lru logic doesn't crash under load.
 // It reproduces https://github.com/expanse-org/go-expanse/issues/14943
 func TestCacheFileEvict(t *testing.T) {
@@ -75,6 +298,90 @@ func TestCacheFileEvict(t *testing.T) {
 	wg.Wait()
 }
 
+// Tests that dumps round-trip through WriteDump and LoadDump, and that corrupt
+// dumps are rejected.
+func TestDumpRoundTrip(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "ethash-dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	data := make([]uint32, 1024)
+	for i := range data {
+		data[i] = uint32(i) * 0x9e3779b9
+	}
+	path := filepath.Join(tmpdir, "dump")
+	if err := WriteDump(path, data); err != nil {
+		t.Fatalf("failed to write dump: %v", err)
+	}
+	loaded, err := LoadDump(path)
+	if err != nil {
+		t.Fatalf("failed to load dump: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, data) {
+		t.Fatalf("loaded dump mismatch")
+	}
+	// Corrupt the magic and truncate the dump, both must be rejected
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob[0] ^= 0xff
+	if err := ioutil.WriteFile(path, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDump(path); !errors.Is(err, ErrInvalidDumpMagic) {
+		t.Errorf("corrupt magic error mismatch: have %v, want %v", err, ErrInvalidDumpMagic)
+	}
+	if err := ioutil.WriteFile(path, blob[:3], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDump(path); !errors.Is(err, ErrInvalidDumpMagic) {
+		t.Errorf("truncated dump error mismatch: have %v, want %v", err, ErrInvalidDumpMagic)
+	}
+}
+
+// Tests that dumps written on a machine of the opposite endianness are detected
+// and byte swapped on load, rather than misread.
+func TestDumpForeignEndianness(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "ethash-dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	data := []uint32{0x01020304, 0xdeadbeef, 0, 0xffffffff}
+	path := filepath.Join(tmpdir, "dump")
+	if err := WriteDump(path, data); err != nil {
+		t.Fatalf("failed to write dump: %v", err)
+	}
+	// Simulate a dump written with the opposite byte order
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swap(blob)
+	if err := ioutil.WriteFile(path, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadDump(path)
+	if err != nil {
+		t.Fatalf("failed to load foreign dump: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, data) {
+		t.Errorf("foreign dump mismatch: have %x, want %x", loaded, data)
+	}
+	// A magic mixing both byte orders must still be rejected
+	swap(blob[:4])
+	if err := ioutil.WriteFile(path, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDump(path); !errors.Is(err, ErrInvalidDumpMagic) {
+		t.Errorf("mixed magic error mismatch: have %v, want %v", err, ErrInvalidDumpMagic)
+	}
+}
+
 func verifyTest(wg *sync.WaitGroup, e *Ethash, workerIndex, epochs int) {
 	defer wg.Done()
 
@@ -95,7 +402,7 @@ func TestRemoteSealer(t *testing.T) {
 	defer ethash.Close()
 
 	api := &API{ethash}
-	if _, err := api.GetWork(); err != errNoMiningWork {
+	if _, err := api.GetWork(); !errors.Is(err, ErrNoMiningWork) {
 		t.Error("expect to return an error indicate there is no mining work")
 	}
 	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
@@ -128,6 +435,308 @@ func TestRemoteSealer(t *testing.T) {
 	}
 }
 
+func TestResendWork(t *testing.T) {
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	api := &API{ethash}
+	if _, err := api.ResendWork(); !errors.Is(err, ErrNoMiningWork) {
+		t.Fatalf("resend error mismatch: have %v, want %v", err, ErrNoMiningWork)
+	}
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	results := make(chan *types.Block, 1)
+	ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+
+	work, err := api.ResendWork()
+	if err != nil {
+		t.Fatalf("failed to resend work: %v", err)
+	}
+	if work[0] != ethash.SealHash(header).Hex() {
+		t.Fatalf("resent work sealhash mismatch: have %s, want %s", work[0], ethash.SealHash(header).Hex())
+	}
+	if current, _ := api.GetWork(); current != work {
+		t.Fatalf("current work mismatch: have %v, want %v", current, work)
+	}
+	// Submit a solution for the resent work and ensure the block is unchanged
+	if !api.SubmitWork(types.BlockNonce{}, common.HexToHash(work[0]), common.Hash{}) {
+		t.Fatal("resent work submission rejected")
+	}
+	if block := <-results; ethash.SealHash(block.Header()) != ethash.SealHash(header) {
+		t.Errorf("resent block modified")
+	}
+}
+
+// Tests that the current work can be expired on demand, refusing to hand it out
+// until new work is pushed.
+func TestExpireWork(t *testing.T) {
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	api, private := &API{ethash}, &PrivateAPI{ethash}
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block, 1), nil)
+	if _, err := api.GetWork(); err != nil {
+		t.Fatalf("failed to get work: %v", err)
+	}
+	if err := private.ExpireWork(); err != nil {
+		t.Fatalf("failed to expire work: %v", err)
+	}
+	if _, err := api.GetWork(); !errors.Is(err, ErrNoMiningWork) {
+		t.Fatalf("expired work error mismatch: have %v, want %v", err, ErrNoMiningWork)
+	}
+	if api.SubmitWork(types.BlockNonce{}, ethash.SealHash(header), common.Hash{}) {
+		t.Fatal("solution for expired work accepted")
+	}
+	// Push new work and ensure it's handed out again
+	header.Number = big.NewInt(2)
+	ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block, 1), nil)
+	if work, err := api.GetWork(); err != nil || work[0] != ethash.SealHash(header).Hex() {
+		t.Fatalf("new work mismatch: have %v, %v, want %v", work[0], err, ethash.SealHash(header).Hex())
+	}
+}
+
+// Tests that the full header of the current work can be fetched, and that it
+// matches the block pushed for sealing.
+func TestGetWorkHeader(t *testing.T) {
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	api := &API{ethash}
+	if _, err := api.GetWorkHeader(); !errors.Is(err, ErrNoMiningWork) {
+		t.Fatalf("missing work error mismatch: have %v, want %v", err, ErrNoMiningWork)
+	}
+	header := &types.Header{
+		ParentHash: common.HexToHash("0x01"),
+		Coinbase:   common.HexToAddress("0x02"),
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(100),
+		GasLimit:   5000000,
+		Time:       1234,
+		Extra:      []byte("work"),
+	}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block, 1), nil)
+
+	have, err := api.GetWorkHeader()
+	if err != nil {
+		t.Fatalf("failed to get work header: %v", err)
+	}
+	if have.Hash() != header.Hash() {
+		t.Errorf("work header mismatch: have %+v, want %+v", have, header)
+	}
+	if ethash.SealHash(have) != ethash.SealHash(header) {
+		t.Errorf("work header seal hash mismatch: have %x, want %x", ethash.SealHash(have), ethash.SealHash(header))
+	}
+	haveJSON, _ := json.Marshal(have)
+	wantJSON, _ := json.Marshal(header)
+	if !bytes.Equal(haveJSON, wantJSON) {
+		t.Errorf("work header encoding mismatch: have %s, want %s", haveJSON, wantJSON)
+	}
+}
+
+func TestSubmitWorkErrors(t *testing.T) {
+	ethash := NewTester(nil, true)
+	ethash.SetThreads(-1)
+
+	if err := ethash.SubmitWork(types.BlockNonce{}, common.HexToHash("a"), common.Hash{}); !errors.Is(err, ErrNoMiningWork) {
+		t.Errorf("submission without work error mismatch: have %v, want %v", err, ErrNoMiningWork)
+	}
+	// Push an old and a new work package
+	results := make(chan *types.Block, 1)
+	old := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	ethash.Seal(nil, types.NewBlockWithHeader(old), results, nil)
+	ethash.Seal(nil, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1 + staleThreshold), Difficulty: big.NewInt(100)}), results, nil)
+
+	if err := ethash.SubmitWork(types.BlockNonce{}, common.HexToHash("a"), common.Hash{}); !errors.Is(err, ErrInvalidSealHash) {
+		t.Errorf("unknown work submission error mismatch: have %v, want %v", err, ErrInvalidSealHash)
+	}
+	if err := ethash.SubmitWork(types.BlockNonce{}, ethash.SealHash(old), common.Hash{}); !errors.Is(err, ErrStaleWork) {
+		t.Errorf("stale work submission error mismatch: have %v, want %v", err, ErrStaleWork)
+	}
+	ethash.Close()
+	if err := ethash.SubmitWork(types.BlockNonce{}, ethash.SealHash(old), common.Hash{}); !errors.Is(err, ErrSealerStopped) {
+		t.Errorf("stopped sealer submission error mismatch: have %v, want %v", err, ErrSealerStopped)
+	}
+}
+
+// Tests that solutions submitted for an explicit block number are rejected if it
+// doesn't match the work package, and accepted otherwise.
+func TestSubmitWorkFor(t *testing.T) {
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	results := make(chan *types.Block, 1)
+	header := &types.Header{Number: big.NewInt(5), Difficulty: big.NewInt(100)}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+
+	if err := ethash.SubmitWorkFor(6, types.BlockNonce{}, ethash.SealHash(header), common.Hash{}); !errors.Is(err, ErrWorkNumberMismatch) {
+		t.Fatalf("mismatching number error mismatch: have %v, want %v", err, ErrWorkNumberMismatch)
+	}
+	select {
+	case block := <-results:
+		t.Fatalf("mismatching solution delivered: number %d", block.NumberU64())
+	default:
+	}
+	if err := ethash.SubmitWorkFor(5, types.BlockNonce{}, ethash.SealHash(header), common.Hash{}); err != nil {
+		t.Fatalf("matching solution rejected: %v", err)
+	}
+	if block := <-results; block.NumberU64() != 5 {
+		t.Errorf("delivered block number mismatch: have %d, want 5", block.NumberU64())
+	}
+}
+
+// Tests that probe submissions are rejected without verification, and are neither
+// accepted nor counted.
+func TestPingSubmit(t *testing.T) {
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	api := &PrivateAPI{ethash}
+	if ping, err := api.PingSubmit(); err != nil || ping.Reason != errNoMiningWork.Error() {
+		t.Fatalf("idle ping mismatch: have %+v (%v), want reason %q", ping, err, errNoMiningWork)
+	}
+	results := make(chan *types.Block, 1)
+	ethash.Seal(nil, types.NewBlockWithHeader(NewTestHeader(1, 100)), results, nil)
+
+	ping, err := api.PingSubmit()
+	if err != nil {
+		t.Fatalf("failed to ping: %v", err)
+	}
+	if ping.Reason != errProbeSubmission.Error() {
+		t.Errorf("ping reason mismatch: have %q, want %q", ping.Reason, errProbeSubmission)
+	}
+	select {
+	case block := <-results:
+		t.Fatalf("probe solution delivered: nonce %d", block.Nonce())
+	default:
+	}
+	if stats, _ := ethash.ShareStats(false); stats != (ShareStats{}) {
+		t.Errorf("probe counted as share: %+v", stats)
+	}
+}
+
+// Tests that resubmitting an already accepted solution is flagged as duplicate
+// without being processed again.
+func TestDuplicateSubmission(t *testing.T) {
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	results, stop := make(chan *types.Block, 2), make(chan struct{})
+	defer close(stop)
+	ethash.Seal(nil, types.NewBlockWithHeader(header), results, stop)
+
+	sealhash := ethash.SealHash(header)
+	if err := ethash.SubmitWork(types.EncodeNonce(1), sealhash, common.Hash{}); err != nil {
+		t.Fatalf("failed to submit solution: %v", err)
+	}
+	if err := ethash.SubmitWork(types.EncodeNonce(1), sealhash, common.Hash{}); !errors.Is(err, ErrDuplicateWork) {
+		t.Fatalf("duplicate error mismatch: have %v, want %v", err, ErrDuplicateWork)
+	}
+	// A different solution for the same work is still processed
+	if err := ethash.SubmitWork(types.EncodeNonce(2), sealhash, common.Hash{}); err != nil {
+		t.Fatalf("failed to submit second solution: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("result count mismatch: have %d, want %d", len(results), 2)
+	}
+	stats, err := ethash.ShareStats(false)
+	if err != nil {
+		t.Fatalf("failed to retrieve share stats: %v", err)
+	}
+	if want := (ShareStats{Valid: 2}); stats != want {
+		t.Errorf("share stats mismatch: have %+v, want %+v", stats, want)
+	}
+}
+
+func TestGetShareStats(t *testing.T) {
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+	api := &API{ethash}
+
+	// Push an old and a new work package
+	results := make(chan *types.Block, 2)
+	old := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	cur := &types.Header{Number: big.NewInt(1 + staleThreshold), Difficulty: big.NewInt(100)}
+	ethash.Seal(nil, types.NewBlockWithHeader(old), results, nil)
+	ethash.Seal(nil, types.NewBlockWithHeader(cur), results, nil)
+
+	// Submit a mix of valid, stale and invalid shares
+	api.SubmitWork(types.BlockNonce{}, ethash.SealHash(cur), common.Hash{})
+	api.SubmitWork(types.EncodeNonce(1), ethash.SealHash(cur), common.Hash{})
+	api.SubmitWork(types.BlockNonce{}, ethash.SealHash(old), common.Hash{})
+	api.SubmitWork(types.BlockNonce{}, common.HexToHash("deadbeef"), common.Hash{})
+
+	want := ShareStats{Valid: 2, Stale: 1, Invalid: 1}
+	stats, err := api.GetShareStats()
+	if err != nil {
+		t.Fatalf("failed to retrieve share stats: %v", err)
+	}
+	if stats != want {
+		t.Errorf("share stats mismatch: have %+v, want %+v", stats, want)
+	}
+	if stats, _ = (&PrivateAPI{ethash}).ResetShareStats(); stats != want {
+		t.Errorf("reset share stats mismatch: have %+v, want %+v", stats, want)
+	}
+	if stats, _ = api.GetShareStats(); stats != (ShareStats{}) {
+		t.Errorf("share stats not reset: have %+v", stats)
+	}
+	// The exported metrics must stay monotonic across resets
+	if have := ethash.remote.accepted.Count(); have != 2 {
+		t.Errorf("accepted counter mismatch after reset: have %d, want 2", have)
+	}
+	api.SubmitWork(types.BlockNonce{}, common.HexToHash("deadbeef"), common.Hash{})
+	if stats, _ = api.GetShareStats(); stats != (ShareStats{Invalid: 1}) {
+		t.Errorf("share stats mismatch after reset: have %+v, want %+v", stats, ShareStats{Invalid: 1})
+	}
+}
+
+func TestTestSeal(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	block, err := ethash.SealBlocking(ctx, nil, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	api := &API{ethash}
+
+	// A valid seal must pass, reporting the sealed values
+	check, err := api.TestSeal(block.Header())
+	if err != nil {
+		t.Fatalf("failed to test seal: %v", err)
+	}
+	if !check.Valid || check.Error != "" {
+		t.Fatalf("valid seal rejected: %s", check.Error)
+	}
+	if check.MixDigest != block.MixDigest() {
+		t.Errorf("mix digest mismatch: have %x, want %x", check.MixDigest, block.MixDigest())
+	}
+	if check.Target.ToInt().Cmp(CalcTarget(block.Header())) != 0 {
+		t.Errorf("target mismatch: have %v, want %v", check.Target, CalcTarget(block.Header()))
+	}
+	// An invalid seal must fail, without having been remembered as trusted
+	forged := block.Header()
+	forged.MixDigest = common.HexToHash("deadbeef")
+	if check, err = api.TestSeal(forged); err != nil {
+		t.Fatalf("failed to test seal: %v", err)
+	}
+	if check.Valid || check.Error != errInvalidMixDigest.Error() {
+		t.Errorf("invalid seal result mismatch: valid %v, error %q", check.Valid, check.Error)
+	}
+	if check.MixDigest != block.MixDigest() {
+		t.Errorf("recomputed mix digest mismatch: have %x, want %x", check.MixDigest, block.MixDigest())
+	}
+}
+
 func TestHashRate(t *testing.T) {
 	var (
 		hashrate = []hexutil.Uint64{100, 200, 300}
@@ -153,13 +762,187 @@ func TestHashRate(t *testing.T) {
 	}
 }
 
+// Tests that the hash rate is converted to the requested units, and that the
+// meter snapshot reports the raw local totals.
+func TestHashrateUnits(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	if !(&API{ethash}).SubmitHashRate(hexutil.Uint64(3*GigaHashPerSecond), common.HexToHash("a")) {
+		t.Fatal("remote miner submit hashrate failed")
+	}
+	tests := []struct {
+		unit HashrateUnit
+		want float64
+	}{
+		{0, 3e9},
+		{HashPerSecond, 3e9},
+		{KiloHashPerSecond, 3e6},
+		{MegaHashPerSecond, 3e3},
+		{GigaHashPerSecond, 3},
+	}
+	for _, tt := range tests {
+		if have := ethash.HashrateIn(tt.unit); have != tt.want {
+			t.Errorf("unit %d: hashrate mismatch: have %v, want %v", tt.unit, have, tt.want)
+		}
+	}
+	ethash.hashrate.Mark(1000)
+	snapshot := ethash.HashrateSnapshot()
+	if snapshot.Count != 1000 {
+		t.Errorf("snapshot count mismatch: have %d, want 1000", snapshot.Count)
+	}
+	if snapshot.Mean < 0 {
+		t.Errorf("snapshot mean negative: %v", snapshot.Mean)
+	}
+}
+
+// Tests that a spurious hash rate submission doesn't distort the total if the
+// submissions are smoothed over a window.
+func TestHashRateOutlier(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, HashrateWindow: 5}, nil, false)
+	defer ethash.Close()
+
+	api := &API{ethash}
+	id := common.HexToHash("a")
+	for _, rate := range []uint64{100, 110, math.MaxUint64, 90, 100} {
+		if !api.SubmitHashRate(hexutil.Uint64(rate), id) {
+			t.Fatal("remote miner submit hashrate failed")
+		}
+		if tot := ethash.Hashrate(); tot > 1000 {
+			t.Fatalf("total hashrate distorted by outlier: %v", tot)
+		}
+	}
+	if tot := ethash.Hashrate(); tot != 100 {
+		t.Errorf("total hashrate mismatch: have %v, want %v", tot, 100)
+	}
+}
+
+func TestHashRateExpiry(t *testing.T) {
+	clock := new(mclock.Simulated)
+	ethash := New(Config{PowMode: ModeTest, Clock: clock}, nil, false)
+	defer ethash.Close()
+
+	api := &API{ethash}
+	api.SubmitHashRate(hexutil.Uint64(100), common.HexToHash("a"))
+	clock.Run(remoteRateTTL / 2)
+	api.SubmitHashRate(hexutil.Uint64(200), common.HexToHash("b"))
+
+	if tot := ethash.Hashrate(); tot != 300 {
+		t.Fatalf("total hashrate mismatch: have %v, want %v", tot, 300)
+	}
+	// Expire the first submitter, but not the second one
+	clock.Run(remoteRateTTL/2 + time.Second)
+	if tot := ethash.Hashrate(); tot != 200 {
+		t.Fatalf("total hashrate mismatch after expiry: have %v, want %v", tot, 200)
+	}
+	if miners := ethash.RemoteMiners(); len(miners) != 1 || miners[0].ID != common.HexToHash("b") {
+		t.Fatalf("remote miners mismatch after expiry: %v", miners)
+	}
+}
+
+func TestRemoteMiners(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	if miners := ethash.RemoteMiners(); len(miners) != 0 {
+		t.Fatalf("expected no remote miners, got %d", len(miners))
+	}
+	api := &API{ethash}
+	ids := []common.Hash{common.HexToHash("a"), common.HexToHash("b")}
+	for i, id := range ids {
+		if res := api.SubmitHashRate(hexutil.Uint64(100*(i+1)), id); !res {
+			t.Fatal("remote miner submit hashrate failed")
+		}
+	}
+	miners := api.GetRemoteMiners()
+	if len(miners) != len(ids) {
+		t.Fatalf("remote miner count mismatch: have %d, want %d", len(miners), len(ids))
+	}
+	for i, miner := range miners {
+		if miner.ID != ids[i] {
+			t.Errorf("miner %d id mismatch: have %x, want %x", i, miner.ID, ids[i])
+		}
+		if want := hexutil.Uint64(100 * (i + 1)); miner.Hashrate != want {
+			t.Errorf("miner %d hashrate mismatch: have %d, want %d", i, miner.Hashrate, want)
+		}
+		if miner.LastSeen.IsZero() {
+			t.Errorf("miner %d has no last seen time", i)
+		}
+	}
+}
+
+func TestMetricsRegistry(t *testing.T) {
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	// Submit one acceptable and one bogus solution
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block, 1), nil)
+
+	api := &API{ethash}
+	api.SubmitWork(types.BlockNonce{}, ethash.SealHash(header), common.Hash{})
+	api.SubmitWork(types.BlockNonce{}, common.HexToHash("deadbeef"), common.Hash{})
+
+	// Scrape the collector and ensure the counters are reported
+	rec := httptest.NewRecorder()
+	prometheus.Handler(ethash.MetricsRegistry()).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+	for _, want := range []string{"ethash_hashrate 0", "ethash_remote_accepted 1", "ethash_remote_rejected 1"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scraped metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+// Tests that periodic self-tests run and succeed if enabled, without being
+// reported or accounted as locally sealed blocks.
+func TestSelfTest(t *testing.T) {
+	var found int32
+	logger := log.New()
+	logger.SetHandler(log.FuncHandler(func(r *log.Record) error {
+		if r.Msg == "Ethash nonce found" {
+			atomic.AddInt32(&found, 1)
+		}
+		return nil
+	}))
+	ethash := New(Config{PowMode: ModeTest, CachesInMem: 1, SelfTestInterval: 10 * time.Millisecond, Log: logger}, nil, false)
+	defer ethash.Close()
+
+	for start := time.Now(); ethash.selfPass.Count() < 2; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("self-tests not advancing: %d passed", ethash.selfPass.Count())
+		}
+	}
+	if failed := ethash.selfFail.Count(); failed != 0 {
+		t.Errorf("self-test failures: have %d, want 0", failed)
+	}
+	// The self-test must have sealed through the mining dataset
+	if !ethash.datasets.cache.Contains(uint64(0)) {
+		t.Errorf("self-test didn't generate the mining dataset")
+	}
+	if n := atomic.LoadInt32(&found); n != 0 {
+		t.Errorf("self-test seals logged as found nonces: %d", n)
+	}
+	if count := ethash.hashrate.Count(); count != 0 {
+		t.Errorf("self-test accounted in hash rate: %d hashes", count)
+	}
+	ethash.sealedLock.Lock()
+	remembered := ethash.sealed.Len()
+	ethash.sealedLock.Unlock()
+	if remembered != 0 {
+		t.Errorf("self-test seals remembered: %d", remembered)
+	}
+}
+
 func TestClosedRemoteSealer(t *testing.T) {
 	ethash := NewTester(nil, false)
 	time.Sleep(1 * time.Second) // ensure exit channel is listening
 	ethash.Close()
 
 	api := &API{ethash}
-	if _, err := api.GetWork(); err != errEthashStopped {
+	if _, err := api.GetWork(); !errors.Is(err, ErrSealerStopped) {
 		t.Error("expect to return an error to indicate ethash is stopped")
 	}
 
@@ -167,3 +950,374 @@ func TestClosedRemoteSealer(t *testing.T) {
 		t.Error("expect to return false when submit hashrate to a stopped ethash")
 	}
 }
+
+// Tests that the seed hash of a block's epoch is reported over the API.
+func TestGetSeedHash(t *testing.T) {
+	tests := []struct {
+		number uint64
+		want   common.Hash
+	}{
+		{0, common.Hash{}},
+		{epochLength - 1, common.Hash{}},
+		{epochLength, common.HexToHash("0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563")},
+		{2 * epochLength, common.HexToHash("0x510e4e770828ddbf7f7b00ab00a9f6adaf81c0dc9cc85f1f8249c256942d61d9")},
+		{2*epochLength + 1, common.HexToHash("0x510e4e770828ddbf7f7b00ab00a9f6adaf81c0dc9cc85f1f8249c256942d61d9")},
+	}
+	api := &API{NewTester(nil, false)}
+	defer api.ethash.Close()
+
+	for _, tt := range tests {
+		if have, err := api.GetSeedHash(hexutil.Uint64(tt.number)); err != nil || have != tt.want {
+			t.Errorf("block %d: seed hash mismatch: have %x, %v, want %x", tt.number, have, err, tt.want)
+		}
+	}
+}
+
+// Tests that the last local solution is reported after a successful seal.
+func TestGetLastSeal(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	api := &API{ethash}
+
+	if _, err := api.GetLastSeal(); !errors.Is(err, ErrNoLocalSeal) {
+		t.Fatalf("error mismatch before sealing: have %v, want %v", err, ErrNoLocalSeal)
+	}
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	block, err := ethash.SealBlocking(context.Background(), nil, types.NewBlockWithHeader(header))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	seal, err := api.GetLastSeal()
+	if err != nil {
+		t.Fatalf("failed to retrieve last seal: %v", err)
+	}
+	if want := ethash.SealHash(header); seal.SealHash != want {
+		t.Errorf("seal hash mismatch: have %x, want %x", seal.SealHash, want)
+	}
+	if seal.Nonce != block.Header().Nonce {
+		t.Errorf("nonce mismatch: have %x, want %x", seal.Nonce, block.Header().Nonce)
+	}
+	if seal.MixDigest != block.MixDigest() {
+		t.Errorf("mix digest mismatch: have %x, want %x", seal.MixDigest, block.MixDigest())
+	}
+	if seal.Time.IsZero() || time.Since(seal.Time) > time.Minute {
+		t.Errorf("seal time out of range: %v", seal.Time)
+	}
+}
+
+// Tests that the number of concurrent PoW verifications is bounded if requested.
+func TestMaxVerifyConcurrency(t *testing.T) {
+	sealer := NewTester(nil, false)
+	defer sealer.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	block, err := sealer.SealBlocking(context.Background(), nil, types.NewBlockWithHeader(header))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	const limit = 2
+
+	ethash := New(Config{PowMode: ModeTest, MaxVerifyConcurrency: limit}, nil, false)
+	defer ethash.Close()
+
+	var active, peak int32
+	ethash.verifyHook = func() {
+		running := atomic.AddInt32(&active, 1)
+		for {
+			max := atomic.LoadInt32(&peak)
+			if running <= max || atomic.CompareAndSwapInt32(&peak, max, running) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+	}
+	var pend sync.WaitGroup
+	for i := 0; i < 4*limit; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+			if err := ethash.VerifySeal(nil, block.Header()); err != nil {
+				t.Errorf("failed to verify seal: %v", err)
+			}
+		}()
+	}
+	pend.Wait()
+	if peak > limit {
+		t.Errorf("concurrent verifications exceeded limit: have %d, want <= %d", peak, limit)
+	}
+	if peak < limit {
+		t.Errorf("verifications not run concurrently: have %d, want %d", peak, limit)
+	}
+}
+
+// Tests that invalid seals are counted but accepted in observe-only mode.
+func TestObserveOnly(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 128)}
+	if err := ethash.VerifySeal(nil, header); err == nil {
+		t.Fatalf("invalid seal accepted in strict mode")
+	}
+	ethash.config.ObserveOnly = true
+	if err := ethash.VerifySeal(nil, header); err != nil {
+		t.Fatalf("invalid seal rejected in observe-only mode: %v", err)
+	}
+	if count := ethash.observed.Count(); count != 1 {
+		t.Errorf("observed failure count mismatch: have %d, want 1", count)
+	}
+	// Valid seals must not be counted
+	header.Difficulty = big.NewInt(1)
+	block, err := ethash.SealBlocking(context.Background(), nil, types.NewBlockWithHeader(header))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if err := ethash.VerifySeal(nil, block.Header()); err != nil {
+		t.Fatalf("valid seal rejected: %v", err)
+	}
+	if count := ethash.observed.Count(); count != 1 {
+		t.Errorf("observed failure count mismatch: have %d, want 1", count)
+	}
+}
+
+// Tests that the PoW execution times of seal verifications are only recorded if
+// enabled, and exposed both via the API and the metrics registry.
+func TestPoWLatency(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	api := &API{ethash}
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	ethash.VerifyOnce(header)
+	if _, err := api.GetPoWLatency(); err == nil {
+		t.Fatalf("latency reported while recording disabled")
+	}
+	ethash.config.RecordPoWLatency = true
+	for i := uint64(0); i < 5; i++ {
+		header.Nonce = types.EncodeNonce(i)
+		ethash.VerifyOnce(header)
+	}
+	latency, err := api.GetPoWLatency()
+	if err != nil {
+		t.Fatalf("failed to retrieve latency: %v", err)
+	}
+	if latency.Samples != 5 {
+		t.Errorf("sample count mismatch: have %d, want %d", latency.Samples, 5)
+	}
+	if latency.P50 <= 0 || latency.P50 > latency.Max {
+		t.Errorf("invalid latency percentiles: %+v", latency)
+	}
+	if ethash.MetricsRegistry().Get("ethash/verify/latency") == nil {
+		t.Errorf("latency histogram not registered")
+	}
+}
+
+// Tests that streamed headers are verified and reported in input order.
+func TestVerifyStream(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	// Create a batch of headers, sealing every other one
+	var (
+		headers []*types.Header
+		valid   []bool
+	)
+	for i := 0; i < 8; i++ {
+		header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1000), Time: uint64(i)}
+		if i%2 == 0 {
+			block, err := ethash.SealBlocking(context.Background(), nil, types.NewBlockWithHeader(header))
+			if err != nil {
+				t.Fatalf("failed to seal block %d: %v", i, err)
+			}
+			header = block.Header()
+		}
+		headers = append(headers, header)
+		valid = append(valid, i%2 == 0)
+	}
+	in := make(chan *types.Header)
+	go func() {
+		defer close(in)
+		for _, header := range headers {
+			in <- header
+		}
+	}()
+	var i int
+	for err := range ethash.VerifyStream(in) {
+		if i >= len(headers) {
+			t.Fatalf("too many results: have %d, want %d", i+1, len(headers))
+		}
+		if (err == nil) != valid[i] {
+			t.Errorf("header %d: verification mismatch: have %v, want valid %v", i, err, valid[i])
+		}
+		i++
+	}
+	if i != len(headers) {
+		t.Errorf("result count mismatch: have %d, want %d", i, len(headers))
+	}
+}
+
+// Tests that the remote submission queues are sized as configured and that
+// concurrent submissions through them are all processed.
+func TestSubmitBuffers(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, SubmitWorkBuffer: 4, SubmitRateBuffer: 16}, nil, false)
+	defer ethash.Close()
+
+	if have := cap(ethash.remote.submitWorkCh); have != 4 {
+		t.Errorf("work submission buffer mismatch: have %d, want 4", have)
+	}
+	if have := cap(ethash.remote.submitRateCh); have != 16 {
+		t.Errorf("hash rate submission buffer mismatch: have %d, want 16", have)
+	}
+	// Submit lots of hash rates concurrently and ensure none are lost
+	var (
+		api  = &API{ethash}
+		pend sync.WaitGroup
+	)
+	for i := 0; i < 64; i++ {
+		pend.Add(1)
+		go func(i int) {
+			defer pend.Done()
+			if !api.SubmitHashRate(hexutil.Uint64(1), common.Hash{byte(i)}) {
+				t.Errorf("hash rate %d not accepted", i)
+			}
+		}(i)
+	}
+	pend.Wait()
+	if have := api.GetHashrate(); have != 64 {
+		t.Errorf("total hash rate mismatch: have %d, want 64", have)
+	}
+}
+
+// Tests that negative submission queue sizes are treated as unbuffered instead
+// of crashing the engine.
+func TestNegativeSubmitBuffers(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, SubmitWorkBuffer: -1, SubmitRateBuffer: -1}, nil, false)
+	defer ethash.Close()
+
+	if have := cap(ethash.remote.submitWorkCh); have != 0 {
+		t.Errorf("work submission buffer mismatch: have %d, want 0", have)
+	}
+	if have := cap(ethash.remote.submitRateCh); have != 0 {
+		t.Errorf("hash rate submission buffer mismatch: have %d, want 0", have)
+	}
+}
+
+// Benchmarks concurrent hash rate submissions with and without a queue.
+func BenchmarkSubmitHashRate(b *testing.B) {
+	for _, buffer := range []int{0, 64} {
+		b.Run(fmt.Sprintf("buffer-%d", buffer), func(b *testing.B) {
+			ethash := New(Config{PowMode: ModeTest, SubmitRateBuffer: buffer}, nil, false)
+			defer ethash.Close()
+			api := &API{ethash}
+
+			b.RunParallel(func(pb *testing.PB) {
+				id := common.Hash{byte(rand.Int())}
+				for pb.Next() {
+					api.SubmitHashRate(1, id)
+				}
+			})
+		})
+	}
+}
+
+// Tests that the effective configuration of shared engines is the one of the
+// shared instance.
+func TestEffectiveConfig(t *testing.T) {
+	defer sharedEthash.SetThreads(sharedEthash.Threads())
+
+	ethash := NewShared()
+	defer ethash.Close()
+	ethash.SetThreads(3)
+
+	config, threads := ethash.EffectiveConfig()
+	if threads != 3 {
+		t.Errorf("effective threads mismatch: have %d, want 3", threads)
+	}
+	if config.PowMode != ModeNormal || config.CachesInMem != 3 {
+		t.Errorf("effective config mismatch: have mode %v caches %d, want mode %v caches 3", config.PowMode, config.CachesInMem, ModeNormal)
+	}
+	// Disabled and all-core mining must be resolved
+	ethash.SetThreads(-1)
+	if _, threads = ethash.EffectiveConfig(); threads != 0 {
+		t.Errorf("disabled threads mismatch: have %d, want 0", threads)
+	}
+	ethash.SetThreads(0)
+	if _, threads = ethash.EffectiveConfig(); threads != runtime.NumCPU() {
+		t.Errorf("all-core threads mismatch: have %d, want %d", threads, runtime.NumCPU())
+	}
+}
+
+// Tests that the engine configuration is exported as JSON without any of the
+// runtime hooks, filesystem paths or pool coordination data.
+func TestGetEngineConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ethash := New(Config{
+		PowMode:        ModeTest,
+		CachesInMem:    1,
+		CacheDir:       filepath.Join(dir, "s3cr3t-cache"),
+		DatasetDir:     filepath.Join(dir, "s3cr3t-dag"),
+		VerifyCacheDir: filepath.Join(dir, "s3cr3t-verify"),
+		RecordWork:     filepath.Join(dir, "s3cr3t-work"),
+		ExtraNonce:     []byte("s3cr3t"),
+		LogContext:     map[string]interface{}{"secret": "s3cr3t"},
+	}, nil, false)
+	defer ethash.Close()
+	ethash.config.MineGate = func() bool { return true }
+
+	blob, err := json.Marshal((&API{ethash}).GetEngineConfig())
+	if err != nil {
+		t.Fatalf("failed to encode config: %v", err)
+	}
+	if bytes.Contains(blob, []byte("s3cr3t")) || bytes.Contains(blob, []byte(hexutil.Encode([]byte("s3cr3t")))) {
+		t.Errorf("sensitive fields leaked: %s", blob)
+	}
+	var config EngineConfig
+	if err := json.Unmarshal(blob, &config); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if config.PowMode != ModeTest || config.CachesInMem != 1 {
+		t.Errorf("config mismatch: have mode %d, caches %d, want mode %d, caches %d", config.PowMode, config.CachesInMem, ModeTest, 1)
+	}
+}
+
+// Tests that the supported RPC methods include the well known mining methods, but
+// none of the private ones.
+func TestSupportedMethods(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	methods := make(map[string]bool)
+	for _, method := range (&API{ethash}).SupportedMethods() {
+		methods[method] = true
+	}
+	for _, want := range []string{"getWork", "submitWork", "submitHashRate", "getHashrate", "supportedMethods"} {
+		if !methods[want] {
+			t.Errorf("supported methods missing %q: %v", want, methods)
+		}
+	}
+	for _, private := range []string{"setNotifyURLs", "expireWork", "pingSubmit", "resetShareStats", "setNotifyEnabled"} {
+		if methods[private] {
+			t.Errorf("private method %q exposed publicly", private)
+		}
+	}
+}
+
+// Tests that the engine reports its name and algorithm revision.
+func TestGetEngineInfo(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	info := (&API{ethash}).GetEngineInfo()
+	if info.Name != "ethash" {
+		t.Errorf("engine name mismatch: have %q, want %q", info.Name, "ethash")
+	}
+	if info.AlgorithmRevision != algorithmRevision {
+		t.Errorf("algorithm revision mismatch: have %d, want %d", info.AlgorithmRevision, algorithmRevision)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+)
+
+// EventKind identifies what happened in an engine event.
+type EventKind int
+
+const (
+	WorkPushed   EventKind = iota // New work was handed to the remote sealer
+	SealFound                     // A local mining thread found a valid nonce
+	SealVerified                  // A seal passed verification
+	VerifyFailed                  // A seal failed verification
+)
+
+// String implements fmt.Stringer.
+func (kind EventKind) String() string {
+	switch kind {
+	case WorkPushed:
+		return "WorkPushed"
+	case SealFound:
+		return "SealFound"
+	case SealVerified:
+		return "SealVerified"
+	case VerifyFailed:
+		return "VerifyFailed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a sealing or verification event of the engine.
+type Event struct {
+	Kind     EventKind
+	Time     time.Time   // Time the event happened
+	Number   uint64      // Number of the block concerned
+	SealHash common.Hash // Seal hash of the block concerned
+	Nonce    uint64      // Nonce found or verified, unset for pushed work
+	Err      error       // Reason of the verification failure, VerifyFailed only
+}
+
+// eventBus fans out engine events to the subscribers, dropping the events of
+// any subscriber not keeping up instead of stalling the engine. The zero value
+// is ready to use.
+type eventBus struct {
+	subs map[chan Event]struct{}
+	lock sync.Mutex
+}
+
+// subscribe registers a new subscriber with the given channel buffer size,
+// returning its event channel and a function cancelling the subscription.
+func (bus *eventBus) subscribe(buffer int) (<-chan Event, func()) {
+	if buffer < 0 {
+		buffer = 0
+	}
+	ch := make(chan Event, buffer)
+
+	bus.lock.Lock()
+	if bus.subs == nil {
+		bus.subs = make(map[chan Event]struct{})
+	}
+	bus.subs[ch] = struct{}{}
+	bus.lock.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			bus.lock.Lock()
+			delete(bus.subs, ch)
+			close(ch)
+			bus.lock.Unlock()
+		})
+	}
+}
+
+// active reports whether there are any subscribers, allowing callers to skip
+// assembling events nobody listens to.
+func (bus *eventBus) active() bool {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	return len(bus.subs) > 0
+}
+
+// post delivers the event to every subscriber ready to receive it.
+func (bus *eventBus) post(event Event) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	if len(bus.subs) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for ch := range bus.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// SubscribeEvents subscribes to the sealing and verification events of the
+// engine. Events are delivered through the returned channel, buffered to the
+// given size, and dropped whenever the subscriber falls behind. The returned
+// function cancels the subscription and closes the channel.
+func (ethash *Ethash) SubscribeEvents(buffer int) (<-chan Event, func()) {
+	return ethash.events.subscribe(buffer)
+}
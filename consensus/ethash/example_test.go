@@ -0,0 +1,55 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash_test
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/expanse-org/go-expanse/consensus/ethash"
+	"github.com/expanse-org/go-expanse/core/types"
+)
+
+func ExampleEthash_VerifyOnce() {
+	engine := ethash.NewTester(nil, false)
+	defer engine.Close()
+
+	// Seal a block to have a valid proof-of-work at hand
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	block, err := engine.SealBlocking(context.Background(), nil, types.NewBlockWithHeader(header))
+	if err != nil {
+		fmt.Println("failed to seal block:", err)
+		return
+	}
+	// Repeatedly verify the seal, measuring the average verification time
+	const rounds = 10
+
+	start := time.Now()
+	for i := 0; i < rounds; i++ {
+		if err := engine.VerifyOnce(block.Header()); err != nil {
+			fmt.Println("invalid seal:", err)
+			return
+		}
+	}
+	elapsed := time.Since(start) / rounds
+	fmt.Println("verified", rounds, "seals", elapsed > 0)
+
+	// Output:
+	// verified 10 seals true
+}
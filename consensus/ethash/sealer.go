@@ -20,37 +20,294 @@ import (
 	"bytes"
 	"context"
 	crand "crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
 	"math/big"
 	"math/rand"
 	"net/http"
 	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/mclock"
 	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/metrics"
+	"github.com/hashicorp/golang-lru/simplelru"
 )
 
 const (
 	// staleThreshold is the maximum depth of the acceptable stale but valid ethash solution.
 	staleThreshold = 7
+
+	// remoteGCInterval is the default interval at which the remote sealer sweeps
+	// stale hash rate submissions and pending work.
+	remoteGCInterval = 5 * time.Second
+
+	// remoteRateTTL is the time after which a remote sealer's submitted hash rate
+	// is considered stale and no longer counted.
+	remoteRateTTL = 10 * time.Second
+
+	// submittedCacheSize is the number of accepted remote solutions remembered
+	// to detect duplicate submissions.
+	submittedCacheSize = 64
+
+	// mineGateRecheck is the interval at which a closed mining gate is polled.
+	mineGateRecheck = 100 * time.Millisecond
+
+	// progressInterval is the minimum interval between two sealing progress
+	// reports.
+	progressInterval = 100 * time.Millisecond
+
+	// throttleInterval is the interval at which the number of active mining
+	// threads is adjusted towards the target hash rate.
+	throttleInterval = 5 * time.Second
+)
+
+// Errors returned by the remote sealer, which API consumers can check against.
+var (
+	// ErrNoMiningWork is returned if remote work is requested or submitted while
+	// nothing is being sealed.
+	ErrNoMiningWork = errors.New("no mining work available yet")
+
+	// ErrStaleWork is returned if a solution is submitted for work that is too
+	// old to be accepted any more.
+	ErrStaleWork = errors.New("stale mining work")
+
+	// ErrSealerStopped is returned if the remote sealer is accessed after the
+	// engine has been closed.
+	ErrSealerStopped = errors.New("ethash stopped")
+
+	// ErrNoLocalSeal is returned when querying the last local solution before
+	// the local miner found any.
+	ErrNoLocalSeal = errors.New("no block sealed locally yet")
+
+	// ErrInvalidSubmission is returned if a solution is submitted with obviously
+	// malformed parameters.
+	ErrInvalidSubmission = errors.New("invalid work submission")
+
+	// ErrInvalidSealHash is returned if a solution is submitted for a seal hash
+	// which doesn't belong to any pending work.
+	ErrInvalidSealHash = errors.New("unknown seal hash")
+
+	// ErrDuplicateWork is returned if a solution is submitted which was already
+	// accepted before. The solution is not processed again.
+	ErrDuplicateWork = errors.New("duplicate work submission")
+
+	// ErrWorkNumberMismatch is returned if a solution is submitted for a block
+	// number other than the one of the work package with the given seal hash.
+	ErrWorkNumberMismatch = errors.New("work block number mismatch")
+
+	// ErrUnknownJob is returned if a seal job to cancel is not in progress.
+	ErrUnknownJob = errors.New("unknown seal job")
+
+	// ErrReplayLiveWork is returned if recorded work is replayed into a remote
+	// sealer which is handing out real work.
+	ErrReplayLiveWork = errors.New("cannot replay work while sealing real work")
+
+	// ErrMalformedWork is returned by ParseWork if a work package can't be
+	// decoded. The wrapping error describes the offending field.
+	ErrMalformedWork = errors.New("malformed work package")
 )
 
 var (
-	errNoMiningWork      = errors.New("no mining work available yet")
-	errInvalidSealResult = errors.New("invalid or stale proof-of-work solution")
+	errNoMiningWork      = ErrNoMiningWork
+	errInvalidSealResult = errors.New("invalid proof-of-work solution")
+	errProbeSubmission   = errors.New("probe submission not accepted")
 )
 
 // Seal implements consensus.Engine, attempting to find a nonce that satisfies
 // the block's difficulty requirements.
 func (ethash *Ethash) Seal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	if n := ethash.config.ResultsBuffer; n > 0 && results != nil {
+		results = ethash.bufferResults(results, n, stop)
+	}
+	return ethash.seal(chain, block, results, stop, true, nil)
+}
+
+// TrySeal starts sealing the block just like Seal, unless a seal operation is
+// already in progress, in which case it returns false without starting another
+// one competing for the mining threads.
+func (ethash *Ethash) TrySeal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) (bool, error) {
+	ethash.tryLock.Lock()
+	defer ethash.tryLock.Unlock()
+
+	if ethash.IsSealing() {
+		return false, nil
+	}
+	if err := ethash.Seal(chain, block, results, stop); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// bufferResults creates a buffered channel of the given size, forwarding any
+// results written into it to the consumer's channel until sealing is stopped,
+// the engine is closed or a newer seal takes over, in which case the results
+// already buffered are still forwarded. If a result isn't read within
+// ResultSendTimeout, it is dropped and forwarding ends, as the consumer
+// evidently went away.
+func (ethash *Ethash) bufferResults(results chan<- *types.Block, size int, stop <-chan struct{}) chan<- *types.Block {
+	var exitCh chan struct{}
+	if ethash.remote != nil {
+		exitCh = ethash.remote.exitCh
+	}
+	// Retire the forwarder of the previous seal, whose results channel is not
+	// handed to the remote sealer any more. Without a stop channel it would
+	// otherwise linger until the engine is closed.
+	retire := make(chan struct{})
+	ethash.lock.Lock()
+	if ethash.retire != nil {
+		close(ethash.retire)
+	}
+	ethash.retire = retire
+	ethash.lock.Unlock()
+
+	buffer := make(chan *types.Block, size)
+	forward := func(block *types.Block) bool {
+		var (
+			timer   *time.Timer
+			timeout <-chan time.Time
+		)
+		if d := ethash.config.ResultSendTimeout; d > 0 {
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+		select {
+		case results <- block:
+			if timer != nil {
+				timer.Stop()
+			}
+			return true
+		case <-timeout:
+			ethash.config.Log.Warn("Sealing result not read in time, dropping", "number", block.NumberU64(), "sealhash", ethash.SealHash(block.Header()), "timeout", ethash.config.ResultSendTimeout)
+		case <-stop:
+		case <-exitCh:
+		}
+		return false
+	}
+	go func() {
+		for {
+			select {
+			case block := <-buffer:
+				if !forward(block) {
+					return
+				}
+			case <-retire:
+				for {
+					select {
+					case block := <-buffer:
+						if !forward(block) {
+							return
+						}
+					default:
+						return
+					}
+				}
+			case <-stop:
+				return
+			case <-exitCh:
+				return
+			}
+		}
+	}()
+	return buffer
+}
+
+// SealBlocking searches for a nonce satisfying the block's difficulty using the
+// local mining threads only, and returns the sealed block once found or an error
+// if the context is cancelled first. The work is not pushed to remote miners.
+func (ethash *Ethash) SealBlocking(ctx context.Context, chain consensus.ChainHeaderReader, block *types.Block) (*types.Block, error) {
+	var (
+		results = make(chan *types.Block, 1)
+		stop    = make(chan struct{})
+	)
+	defer close(stop)
+
+	if err := ethash.seal(chain, block, results, stop, false, nil); err != nil {
+		return nil, err
+	}
+	select {
+	case result := <-results:
+		return result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// selfTestLoop periodically runs a PoW self-test until the engine is closed,
+// skipping it while a seal operation is in progress.
+func (ethash *Ethash) selfTestLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if ethash.IsSealing() {
+				continue
+			}
+			if err := ethash.selfTest(); err != nil {
+				ethash.selfFail.Inc(1)
+				ethash.config.Log.Error("Ethash self-test failed", "err", err)
+			} else {
+				ethash.selfPass.Inc(1)
+			}
+		case <-ethash.remote.exitCh:
+			return
+		}
+	}
+}
+
+// selfTest seals a synthetic difficulty 1 header through the local mining path
+// on a single thread and verifies the result with the verification cache, cross
+// checking the full dataset PoW against the light one. The header is placed in
+// the latest epoch seen, so that its dataset is likely at hand.
+func (ethash *Ethash) selfTest() error {
+	var number uint64
+	if epoch := atomic.LoadUint64(&ethash.epoch); epoch > 0 {
+		number = (epoch - 1) * epochLength
+	}
+	header := &types.Header{
+		Number:     new(big.Int).SetUint64(number),
+		Difficulty: big.NewInt(1),
+		Time:       uint64(time.Now().Unix()),
+		Extra:      []byte("ethash self-test"),
+	}
+	// Mine on a dedicated thread, whose progress isn't reported as sealing nor
+	// accounted in the hash rate and whose result isn't remembered as sealed,
+	// as that would let the verification below skip the PoW computation
+	var (
+		block    = types.NewBlockWithHeader(header)
+		sealhash = ethash.SealHash(header)
+		cursors  = &nonceCursors{sealhash: sealhash, seeds: make([]uint64, 1), nonces: make([]uint64, 1)}
+		abort    = make(chan struct{})
+		found    = make(chan *types.Block)
+	)
+	defer close(abort)
+	go ethash.mine(block, sealhash, 0, 0, cursors, abort, found, true)
+
+	select {
+	case result := <-found:
+		return ethash.VerifyOnce(result.Header())
+	case <-ethash.remote.exitCh:
+		return ErrSealerStopped
+	}
+}
+
+// seal is the actual implementation of Seal, optionally also pushing the work
+// to the remote sealer. A nil job starts a new seal job, otherwise the given one
+// is continued, e.g. when restarting after a thread count change.
+func (ethash *Ethash) seal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}, remote bool, job *sealJob) error {
 	// If we're running a fake PoW, simply return a 0 nonce immediately
-	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeFullFake {
+	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeSemiFake || ethash.config.PowMode == ModeFullFake {
 		header := block.Header()
 		header.Nonce, header.MixDigest = types.BlockNonce{}, common.Hash{}
 		select {
@@ -62,21 +319,30 @@ func (ethash *Ethash) Seal(chain consensus.ChainHeaderReader, block *types.Block
 	}
 	// If we're running a shared PoW, delegate sealing to it
 	if ethash.shared != nil {
-		return ethash.shared.Seal(chain, block, results, stop)
+		return ethash.shared.seal(chain, block, results, stop, remote, job)
+	}
+	// Refuse to seal blocks without a valid PoW target, neither locally nor remotely
+	if difficulty := block.Header().Difficulty; difficulty == nil || difficulty.Sign() <= 0 {
+		return errInvalidDifficulty
+	}
+	if err := ethash.checkNumber(block.Header().Number); err != nil {
+		return err
 	}
+	ethash.noteEpoch(block.NumberU64())
 	// Create a runner and the multiple search threads it directs
 	abort := make(chan struct{})
 
 	ethash.lock.Lock()
 	threads := ethash.threads
-	if ethash.rand == nil {
-		seed, err := crand.Int(crand.Reader, big.NewInt(math.MaxInt64))
-		if err != nil {
-			ethash.lock.Unlock()
-			return err
-		}
-		ethash.rand = rand.New(rand.NewSource(seed.Int64()))
+	if err := ethash.seedRand(); err != nil {
+		ethash.lock.Unlock()
+		return err
+	}
+	if ethash.sealers == nil {
+		ethash.sealers = &sealGroup{abort: make(chan struct{})}
 	}
+	sealers := ethash.sealers
+	sealers.pend.Add(1)
 	ethash.lock.Unlock()
 	if threads == 0 {
 		threads = runtime.NumCPU()
@@ -85,56 +351,113 @@ func (ethash *Ethash) Seal(chain consensus.ChainHeaderReader, block *types.Block
 		threads = 0 // Allows disabling local mining without extra logic around local/remote
 	}
 	// Push new work to remote sealer
-	if ethash.remote != nil {
-		ethash.remote.workCh <- &sealTask{block: block, results: results}
+	if remote && ethash.remote != nil {
+		ethash.remote.start()
+		ethash.remote.workCh <- &sealTask{chain: chain, block: block, results: results}
 	}
+	// The seal hash is the same for all threads, compute it only once
 	var (
-		pend   sync.WaitGroup
-		locals = make(chan *types.Block)
+		pend     sync.WaitGroup
+		locals   = make(chan *types.Block)
+		sealhash = ethash.SealHash(block.Header())
+		cursors  = ethash.trackCursors(sealhash, threads)
+		resumed  = ethash.resumeCursors(sealhash)
 	)
+	if job == nil {
+		job = ethash.startJob(sealhash, block.NumberU64())
+	}
+	atomic.AddInt32(&ethash.sealing, 1)
 	for i := 0; i < threads; i++ {
+		seed := uint64(ethash.rand.Int63())
+		if i < len(resumed) {
+			seed = resumed[i]
+		}
 		pend.Add(1)
 		go func(id int, nonce uint64) {
 			defer pend.Done()
-			ethash.mine(block, id, nonce, abort, locals)
-		}(i, uint64(ethash.rand.Int63()))
+			ethash.mine(block, sealhash, id, nonce, cursors, abort, locals, false)
+		}(i, seed)
+	}
+	if report := ethash.config.ProgressCallback; report != nil && threads > 0 {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+			reportProgress(cursors, report, abort)
+		}()
 	}
 	// Wait until sealing is terminated or a nonce is found
 	go func() {
-		var result *types.Block
+		var (
+			result    *types.Block
+			restarted bool
+		)
 		select {
 		case <-stop:
 			// Outside abort, stop all miner threads
 			close(abort)
+		case <-sealers.abort:
+			// All sealing aborted, stop all miner threads
+			close(abort)
+		case <-job.cancel:
+			// This seal job was cancelled, stop its miner threads
+			close(abort)
 		case result = <-locals:
 			// One of the threads found a block, abort all others
+			ethash.recordSeal(sealhash, result.Header())
+			ethash.events.post(Event{Kind: SealFound, Number: result.NumberU64(), SealHash: sealhash, Nonce: result.Nonce()})
 			select {
 			case results <- result:
 			default:
-				ethash.config.Log.Warn("Sealing result is not read by miner", "mode", "local", "sealhash", ethash.SealHash(block.Header()))
+				ethash.config.Log.Warn("Sealing result is not read by miner", "mode", "local", "sealhash", sealhash)
 			}
 			close(abort)
 		case <-ethash.update:
 			// Thread count was changed on user request, restart
 			close(abort)
-			if err := ethash.Seal(chain, block, results, stop); err != nil {
+			if err := ethash.seal(chain, block, results, stop, remote, job); err != nil {
 				ethash.config.Log.Error("Failed to restart sealing after update", "err", err)
+			} else {
+				restarted = true
 			}
 		}
 		// Wait for all miners to terminate and return the block
 		pend.Wait()
+		if !restarted {
+			ethash.endJob(job)
+		}
+		atomic.AddInt32(&ethash.sealing, -1)
+		sealers.pend.Done()
 	}()
 	return nil
 }
 
+// seedRand creates the random generator picking the starting nonces of the local
+// mining threads, unless already done. The caller must hold ethash.lock.
+func (ethash *Ethash) seedRand() error {
+	if ethash.rand != nil {
+		return nil
+	}
+	source := ethash.config.RandSource
+	if source == nil {
+		source = crand.Reader
+	}
+	seed, err := crand.Int(source, big.NewInt(math.MaxInt64))
+	if err != nil {
+		return err
+	}
+	ethash.rand = rand.New(rand.NewSource(seed.Int64()))
+	return nil
+}
+
 // mine is the actual proof-of-work miner that searches for a nonce starting from
-// seed that results in correct final block difficulty.
-func (ethash *Ethash) mine(block *types.Block, id int, seed uint64, abort chan struct{}, found chan *types.Block) {
+// seed that results in correct final block difficulty. Self-test searches are
+// neither accounted in the hash rate nor remembered as sealed by this node.
+func (ethash *Ethash) mine(block *types.Block, sealhash common.Hash, id int, seed uint64, cursors *nonceCursors, abort chan struct{}, found chan *types.Block, selftest bool) {
 	// Extract some data from the header
 	var (
 		header  = block.Header()
-		hash    = ethash.SealHash(header).Bytes()
-		target  = new(big.Int).Div(two256, header.Difficulty)
+		hash    = sealhash.Bytes()
+		target  = ethash.sealTarget(header)
 		number  = header.Number.Uint64()
 		dataset = ethash.dataset(number, false)
 	)
@@ -142,32 +465,66 @@ func (ethash *Ethash) mine(block *types.Block, id int, seed uint64, abort chan s
 	var (
 		attempts = int64(0)
 		nonce    = seed
+		cursor   = &cursors.nonces[id]
+		start    = time.Now()
 	)
 	logger := ethash.config.Log.New("miner", id)
+	if cpus := ethash.config.ThreadAffinity; len(cpus) > 0 {
+		if err := pinThread(cpus[id%len(cpus)]); err != nil {
+			logger.Warn("Failed to pin mining thread", "cpu", cpus[id%len(cpus)], "err", err)
+		}
+	}
 	logger.Trace("Started ethash search for new nonces", "seed", seed)
+	atomic.StoreUint64(&cursors.seeds[id], seed)
+	atomic.StoreUint64(cursor, seed)
+	if !ethash.awaitMineGate(id, abort) {
+		logger.Trace("Ethash nonce search aborted while gated")
+		return
+	}
 search:
 	for {
 		select {
 		case <-abort:
 			// Mining terminated, update stats and abort
 			logger.Trace("Ethash nonce search aborted", "attempts", nonce-seed)
-			ethash.hashrate.Mark(attempts)
+			if !selftest {
+				ethash.hashrate.Mark(attempts)
+			}
+			atomic.AddUint64(&cursors.tried, uint64(attempts))
+			atomic.StoreUint64(cursor, nonce)
 			break search
 
 		default:
 			// We don't have to update hash rate on every nonce, so update after after 2^X nonces
 			attempts++
 			if (attempts % (1 << 15)) == 0 {
-				ethash.hashrate.Mark(attempts)
+				if !selftest {
+					ethash.hashrate.Mark(attempts)
+				}
+				atomic.AddUint64(&cursors.tried, uint64(attempts))
+				atomic.StoreUint64(cursor, nonce)
 				attempts = 0
+
+				// Idle if mining was paused or throttled meanwhile
+				if !ethash.awaitMineGate(id, abort) {
+					logger.Trace("Ethash nonce search aborted while gated", "attempts", nonce-seed)
+					break search
+				}
 			}
 			// Compute the PoW value of this nonce
 			digest, result := hashimotoFull(dataset.dataset, hash, nonce)
+			result = ethash.extraRounds(result)
 			if new(big.Int).SetBytes(result).Cmp(target) <= 0 {
 				// Correct nonce found, create a new header with it
 				header = types.CopyHeader(header)
 				header.Nonce = types.EncodeNonce(nonce)
 				header.MixDigest = common.BytesToHash(digest)
+				if selftest {
+					logger.Debug("Ethash self-test nonce found", "number", number, "nonce", nonce, "sealhash", sealhash, "elapsed", common.PrettyDuration(time.Since(start)), "attempts", nonce-seed)
+				} else {
+					ethash.rememberSeal(sealhash, header.Nonce, header.MixDigest, result)
+					logger.Info("Ethash nonce found", "number", number, "nonce", nonce, "sealhash", sealhash, "elapsed", common.PrettyDuration(time.Since(start)), "attempts", nonce-seed)
+				}
 
 				// Seal and return a block (if still needed)
 				select {
@@ -178,6 +535,19 @@ search:
 				}
 				break search
 			}
+			// Restart from a fresh seed instead of wrapping around into nonces
+			// already tried at the start of the search
+			if nonce == math.MaxUint64 {
+				ethash.lock.Lock()
+				seed = uint64(ethash.rand.Int63())
+				ethash.lock.Unlock()
+
+				logger.Debug("Ethash nonce space exhausted, reseeding", "seed", seed)
+				nonce = seed
+				atomic.StoreUint64(&cursors.seeds[id], seed)
+				atomic.StoreUint64(cursor, nonce)
+				continue
+			}
 			nonce++
 		}
 	}
@@ -186,6 +556,113 @@ search:
 	runtime.KeepAlive(dataset)
 }
 
+// reportProgress periodically reports the number of nonces tried by the local
+// mining threads of a seal operation until it's aborted. Reports are skipped if
+// no progress was published since the previous one.
+func reportProgress(cursors *nonceCursors, report func(uint64), abort chan struct{}) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	var last uint64
+	for {
+		select {
+		case <-ticker.C:
+			if tried := atomic.LoadUint64(&cursors.tried); tried != last {
+				last = tried
+				report(tried)
+			}
+		case <-abort:
+			return
+		}
+	}
+}
+
+// awaitMineGate blocks until the configured mining gate allows searching for
+// nonces and the given thread is not throttled, returning false if the search
+// was aborted in the meantime.
+func (ethash *Ethash) awaitMineGate(id int, abort chan struct{}) bool {
+	gate := ethash.config.MineGate
+	open := func() bool {
+		if limit := atomic.LoadInt32(&ethash.throttle); limit > 0 && int32(id) >= limit {
+			return false
+		}
+		return gate == nil || gate()
+	}
+	for !open() {
+		select {
+		case <-abort:
+			return false
+		case <-ethash.clock.After(mineGateRecheck):
+		}
+	}
+	return true
+}
+
+// throttleLoop periodically adjusts the number of active mining threads towards
+// the target hash rate until the engine is closed.
+//
+// The hash rate is measured over each interval on its own instead of using the
+// smoothed meter rates, which lag far behind a single thread being toggled and
+// would keep stepping in the same direction long after reaching the target.
+func (ethash *Ethash) throttleLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, lastTime := ethash.hashrate.Count(), time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			count := ethash.hashrate.Count()
+			if elapsed := now.Sub(lastTime).Seconds(); elapsed > 0 {
+				ethash.throttleStep(float64(count-last) / elapsed)
+			}
+			last, lastTime = count, now
+		case <-ethash.remote.exitCh:
+			return
+		}
+	}
+}
+
+// throttleStep activates or deactivates one local mining thread, depending on
+// whether the given local hash rate is below or above the target, keeping at
+// least one thread and at most the configured number of threads active.
+func (ethash *Ethash) throttleStep(rate float64) {
+	limit := ethash.Threads()
+	if limit < 0 {
+		return
+	}
+	if limit == 0 {
+		limit = runtime.NumCPU()
+	}
+	target := ethash.config.TargetHashrate
+
+	active := int(atomic.LoadInt32(&ethash.throttle))
+	if active == 0 || active > limit {
+		active = limit
+	}
+	switch {
+	case rate > target && active > 1:
+		active--
+	case rate < target && active < limit:
+		active++
+	}
+	if active >= limit {
+		active = 0 // All threads may run, don't throttle
+	}
+	if old := atomic.SwapInt32(&ethash.throttle, int32(active)); old != int32(active) {
+		ethash.config.Log.Debug("Adjusted active mining threads", "active", ethash.ActiveThreads(), "threads", limit, "hashrate", rate, "target", target)
+	}
+}
+
+// sealTarget returns the PoW target local sealing searches for, which is the one
+// of the header unless a stricter difficulty floor is configured.
+func (ethash *Ethash) sealTarget(header *types.Header) *big.Int {
+	if ethash.minDiff != nil && header.Difficulty.Cmp(ethash.minDiff) < 0 {
+		return new(big.Int).Div(two256, ethash.minDiff)
+	}
+	return ethash.calcTarget(header)
+}
+
 // This is the timeout for HTTP requests to notify external miners.
 const remoteSealerTimeout = 1 * time.Second
 
@@ -194,47 +671,103 @@ type remoteSealer struct {
 	rates        map[common.Hash]hashrate
 	currentBlock *types.Block
 	currentWork  [4]string
+	currentTime  time.Time // Time the current work package was created
+	currentClean bool      // Whether the current work package obsoleted all previous ones
+	workEpoch    uint64    // Number of work packages obsoleting all previous ones (atomic)
 	notifyCtx    context.Context
 	cancelNotify context.CancelFunc // cancels all notification requests
 	reqWG        sync.WaitGroup     // tracks notification request goroutines
 
 	ethash       *Ethash
 	noverify     bool
+	accepted     metrics.Counter // Counter of accepted remote work submissions
+	rejected     metrics.Counter // Counter of rejected remote work submissions
+	stale        metrics.Counter // Counter of remote work submissions rejected as stale
+	statsBase    ShareStats      // Submission counts at the last share stats reset
+	submitted    *simplelru.LRU  // Recently accepted solutions, to detect duplicate submissions
 	notifyURLs   []string
+	notifyPaused bool          // Whether pushing work to the notify URLs is temporarily disabled
+	notifyTimer  *time.Timer   // Timer pushing coalesced work once the notify interval expires, nil if none pending
+	notifyClean  bool          // Whether any coalesced work obsoleted all previous work
+	lastNotify   time.Time     // Time work was last pushed to the notify URLs
+	notifyLock   sync.RWMutex  // Protects the notify URL list and pause flag, which may be updated at runtime
+	client       *http.Client  // HTTP client used to deliver work notifications
+	recorder     *workRecorder // Recording of the pushed work packages, nil if disabled
 	results      chan<- *types.Block
-	workCh       chan *sealTask   // Notification channel to push new work and relative result channel to remote sealer
-	fetchWorkCh  chan *sealWork   // Channel used for remote sealer to fetch mining work
-	submitWorkCh chan *mineResult // Channel used for remote sealer to submit their mining result
-	fetchRateCh  chan chan uint64 // Channel used to gather submitted hash rate for local or remote sealer.
-	submitRateCh chan *hashrate   // Channel used for remote sealer to submit their mining hashrate
+	workCh       chan *sealTask              // Notification channel to push new work and relative result channel to remote sealer
+	fetchWorkCh  chan *sealWork              // Channel used for remote sealer to fetch mining work
+	resendCh     chan *sealWork              // Channel used to re-notify and fetch the current mining work
+	expireCh     chan chan struct{}          // Channel used to drop the current mining work
+	submitWorkCh chan *mineResult            // Channel used for remote sealer to submit their mining result
+	fetchRateCh  chan chan uint64            // Channel used to gather submitted hash rate for local or remote sealer.
+	submitRateCh chan *hashrate              // Channel used for remote sealer to submit their mining hashrate
+	fetchMinerCh chan chan []RemoteMinerInfo // Channel used to enumerate the remote sealers submitting hash rate
+	fetchStateCh chan chan *minerState       // Channel used to export the current work package
+	fetchStatsCh chan *statsRequest          // Channel used to gather (and reset) the work submission stats
+	restoreCh    chan *restoreTask           // Channel used to reinstate a previously exported work package
 	requestExit  chan struct{}
 	exitCh       chan struct{}
+	startOnce    sync.Once // Ensures the event loop is only started once
 }
 
 // sealTask wraps a seal block with relative result channel for remote sealer thread.
 type sealTask struct {
+	chain   consensus.ChainHeaderReader
 	block   *types.Block
 	results chan<- *types.Block
+	replay  chan error // Outcome of pushing replayed work, nil for real work
 }
 
 // mineResult wraps the pow solution parameters for the specified block.
 type mineResult struct {
+	number    *uint64 // Block number the solution claims to be for, nil if unspecified
 	nonce     types.BlockNonce
 	mixDigest common.Hash
 	hash      common.Hash
+	probe     bool // Latency probe, never verified, accepted nor counted
 
 	errc chan error
 }
 
+// submittedWork identifies an accepted remote solution.
+type submittedWork struct {
+	sealhash common.Hash
+	nonce    types.BlockNonce
+}
+
 // hashrate wraps the hash rate submitted by the remote sealer.
 type hashrate struct {
 	id   common.Hash
-	ping time.Time
+	ping time.Time      // Wall clock time of the submission, reported to users
+	seen mclock.AbsTime // Engine clock time of the submission, used for expiry
 	rate uint64
+	hist []uint64 // Recent submissions of the miner, the rate is their median
 
 	done chan struct{}
 }
 
+// RemoteMinerInfo contains the metadata of a remote sealer which has recently
+// submitted its hash rate.
+type RemoteMinerInfo struct {
+	ID       common.Hash    `json:"id"`       // Identifier the miner submits its hash rate with
+	LastSeen time.Time      `json:"lastSeen"` // Time of the last hash rate submission
+	Hashrate hexutil.Uint64 `json:"hashrate"` // Last reported hash rate
+}
+
+// ShareStats contains the number of remote work submissions since startup (or
+// the last reset), split by outcome.
+type ShareStats struct {
+	Valid   uint64 `json:"valid"`   // Solutions accepted
+	Stale   uint64 `json:"stale"`   // Solutions rejected for being too old
+	Invalid uint64 `json:"invalid"` // Solutions rejected for any other reason
+}
+
+// statsRequest wraps a request for the remote work submission stats.
+type statsRequest struct {
+	reset bool
+	res   chan ShareStats
+}
+
 // sealWork wraps a seal work package for remote sealer.
 type sealWork struct {
 	errc chan error
@@ -243,45 +776,109 @@ type sealWork struct {
 
 func startRemoteSealer(ethash *Ethash, urls []string, noverify bool) *remoteSealer {
 	ctx, cancel := context.WithCancel(context.Background())
+	client := http.DefaultClient
+	if ethash.config.NotifyTransport != nil {
+		client = &http.Client{Transport: ethash.config.NotifyTransport}
+	}
+	submitted, _ := simplelru.NewLRU(submittedCacheSize, nil)
 	s := &remoteSealer{
 		ethash:       ethash,
 		noverify:     noverify,
+		accepted:     metrics.NewCounterForced(),
+		rejected:     metrics.NewCounterForced(),
+		stale:        metrics.NewCounterForced(),
+		submitted:    submitted,
 		notifyURLs:   urls,
+		client:       client,
 		notifyCtx:    ctx,
 		cancelNotify: cancel,
 		works:        make(map[common.Hash]*types.Block),
 		rates:        make(map[common.Hash]hashrate),
 		workCh:       make(chan *sealTask),
 		fetchWorkCh:  make(chan *sealWork),
-		submitWorkCh: make(chan *mineResult),
+		resendCh:     make(chan *sealWork),
+		expireCh:     make(chan chan struct{}),
+		submitWorkCh: make(chan *mineResult, ethash.config.SubmitWorkBuffer),
 		fetchRateCh:  make(chan chan uint64),
-		submitRateCh: make(chan *hashrate),
+		submitRateCh: make(chan *hashrate, ethash.config.SubmitRateBuffer),
+		fetchMinerCh: make(chan chan []RemoteMinerInfo),
+		fetchStateCh: make(chan chan *minerState),
+		fetchStatsCh: make(chan *statsRequest),
+		restoreCh:    make(chan *restoreTask),
 		requestExit:  make(chan struct{}),
 		exitCh:       make(chan struct{}),
 	}
-	go s.loop()
+	if path := ethash.config.RecordWork; path != "" {
+		recorder, err := newWorkRecorder(path)
+		if err != nil {
+			ethash.config.Log.Warn("Failed to start work recording", "path", path, "err", err)
+		} else {
+			s.recorder = recorder
+		}
+	}
+	// Without anyone to notify, nothing happens until the sealer is first used
+	if !ethash.config.LazyRemoteSealer || len(urls) > 0 {
+		s.start()
+	}
 	return s
 }
 
+// start launches the event loop of the remote sealer, unless already running.
+// It must be called before interacting with the sealer through its channels.
+func (s *remoteSealer) start() {
+	s.startOnce.Do(func() { go s.loop() })
+}
+
 func (s *remoteSealer) loop() {
 	defer func() {
 		s.ethash.config.Log.Trace("Ethash remote sealer is exiting")
 		s.cancelNotify()
 		s.reqWG.Wait()
+		if s.notifyTimer != nil {
+			s.notifyTimer.Stop()
+		}
+		if s.recorder != nil {
+			s.recorder.close()
+		}
 		close(s.exitCh)
 	}()
 
-	ticker := time.NewTicker(5 * time.Second)
+	interval := s.ethash.config.RemoteGCInterval
+	if interval <= 0 {
+		interval = remoteGCInterval
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
+		var notifyDue <-chan time.Time
+		if s.notifyTimer != nil {
+			notifyDue = s.notifyTimer.C
+		}
 		select {
 		case work := <-s.workCh:
+			// Replayed work must never displace real work, as solutions for the
+			// latter would be lost.
+			if work.replay != nil {
+				if s.results != nil {
+					work.replay <- ErrReplayLiveWork
+					continue
+				}
+				work.replay <- nil
+			}
 			// Update current work with new received block.
 			// Note same work can be past twice, happens when changing CPU threads.
 			s.results = work.results
 			s.makeWork(work.block)
 			s.notifyWork()
+			if s.recorder != nil {
+				if err := s.recorder.record(s.currentTime, work.block.Header()); err != nil {
+					s.ethash.config.Log.Warn("Failed to record work, stopping recording", "err", err)
+					s.recorder.close()
+					s.recorder = nil
+				}
+			}
+			s.ethash.events.post(Event{Kind: WorkPushed, Number: work.block.NumberU64(), SealHash: common.HexToHash(s.currentWork[0])})
 
 		case work := <-s.fetchWorkCh:
 			// Return current mining work to remote miner.
@@ -291,32 +888,147 @@ func (s *remoteSealer) loop() {
 				work.res <- s.currentWork
 			}
 
+		case work := <-s.resendCh:
+			// Re-issue the current mining work to remote miners. The block is
+			// left untouched, as its seal hash is what the block producer and
+			// the local miner threads are tracking.
+			if s.currentBlock == nil {
+				work.errc <- errNoMiningWork
+			} else {
+				s.makeWork(s.currentBlock)
+				s.notifyWork()
+				work.res <- s.currentWork
+			}
+
+		case done := <-s.expireCh:
+			// Drop the current work on request, as if it went stale. Pending
+			// work is kept until new work arrives, but submissions are refused.
+			s.currentBlock, s.currentWork = nil, [4]string{}
+			close(done)
+
 		case result := <-s.submitWorkCh:
+			// Latency probes only measure the round trip through the sealer,
+			// they are neither verified nor accounted as shares.
+			if result.probe {
+				if s.currentBlock == nil {
+					s.ethash.config.Log.Debug("Probe submission without pending work")
+					result.errc <- errNoMiningWork
+				} else {
+					result.errc <- errProbeSubmission
+				}
+				continue
+			}
 			// Verify submitted PoW solution based on maintained mining blocks.
-			if s.submitWork(result.nonce, result.mixDigest, result.hash) {
-				result.errc <- nil
-			} else {
-				result.errc <- errInvalidSealResult
+			err := s.submitWork(result.number, result.nonce, result.mixDigest, result.hash)
+			switch {
+			case err == nil:
+				s.accepted.Inc(1)
+			case err == ErrDuplicateWork:
+				// Already accounted for when first accepted
+			default:
+				s.rejected.Inc(1)
+				if err == ErrStaleWork {
+					s.stale.Inc(1)
+				}
+			}
+			result.errc <- err
+
+		case req := <-s.fetchStatsCh:
+			// Snapshot the submission counters relative to the last reset. The
+			// counters themselves are exported as metrics, so they are never
+			// cleared, only the reset baseline is moved.
+			total := ShareStats{
+				Valid:   uint64(s.accepted.Count()),
+				Stale:   uint64(s.stale.Count()),
+				Invalid: uint64(s.rejected.Count() - s.stale.Count()),
+			}
+			stats := ShareStats{
+				Valid:   total.Valid - s.statsBase.Valid,
+				Stale:   total.Stale - s.statsBase.Stale,
+				Invalid: total.Invalid - s.statsBase.Invalid,
 			}
+			if req.reset {
+				s.statsBase = total
+			}
+			req.res <- stats
 
 		case result := <-s.submitRateCh:
-			// Trace remote sealer's hash rate by submitted value.
-			s.rates[result.id] = hashrate{rate: result.rate, ping: time.Now()}
+			// Trace remote sealer's hash rate by submitted value, smoothed over
+			// its recent submissions.
+			var history []uint64
+			if prev, ok := s.rates[result.id]; ok && !s.expired(prev) {
+				history = prev.hist
+			}
+			history = append(history, result.rate)
+			if window := s.ethash.config.HashrateWindow; len(history) > window {
+				if window < 1 {
+					window = 1
+				}
+				history = append([]uint64(nil), history[len(history)-window:]...)
+			}
+			s.rates[result.id] = hashrate{rate: medianRate(history), hist: history, ping: time.Now(), seen: s.ethash.clock.Now()}
 			close(result.done)
 
 		case req := <-s.fetchRateCh:
-			// Gather all hash rate submitted by remote sealer.
+			// Gather all hash rate submitted by remote sealer, skipping the
+			// expired ones not yet swept.
 			var total uint64
 			for _, rate := range s.rates {
+				if s.expired(rate) {
+					continue
+				}
 				// this could overflow
 				total += rate.rate
 			}
 			req <- total
 
+		case req := <-s.fetchMinerCh:
+			// Gather the metadata of all remote sealers submitting hash rate.
+			miners := make([]RemoteMinerInfo, 0, len(s.rates))
+			for id, rate := range s.rates {
+				if s.expired(rate) {
+					continue
+				}
+				miners = append(miners, RemoteMinerInfo{ID: id, LastSeen: rate.ping, Hashrate: hexutil.Uint64(rate.rate)})
+			}
+			sort.Slice(miners, func(i, j int) bool {
+				return bytes.Compare(miners[i].ID[:], miners[j].ID[:]) < 0
+			})
+			req <- miners
+
+		case req := <-s.fetchStateCh:
+			// Export the current work package, if any.
+			state := new(minerState)
+			if s.currentBlock != nil {
+				state.Block = s.currentBlock
+				state.Created = uint64(s.currentTime.UnixNano())
+			}
+			req <- state
+
+		case task := <-s.restoreCh:
+			// Reinstate an exported work package. If newer work was already
+			// pushed, only track the restored one for late submissions.
+			// Solutions are rejected until Seal assigns a result channel.
+			if s.currentBlock == nil {
+				s.makeWork(task.block)
+				s.currentTime = task.created
+				s.notifyWork()
+			} else {
+				s.works[s.ethash.SealHash(task.block.Header())] = task.block
+			}
+			close(task.done)
+
+		case <-notifyDue:
+			// Push the latest coalesced work, unless it was dropped meanwhile.
+			s.notifyTimer = nil
+			if s.currentBlock != nil {
+				s.pushWork()
+			}
+
 		case <-ticker.C:
 			// Clear stale submitted hash rate.
 			for id, rate := range s.rates {
-				if time.Since(rate.ping) > 10*time.Second {
+				if s.expired(rate) {
 					delete(s.rates, id)
 				}
 			}
@@ -335,30 +1047,133 @@ func (s *remoteSealer) loop() {
 	}
 }
 
-// makeWork creates a work package for external miner.
-//
-// The work package consists of 3 strings:
-//   result[0], 32 bytes hex encoded current block header pow-hash
-//   result[1], 32 bytes hex encoded seed hash used for DAG
-//   result[2], 32 bytes hex encoded boundary condition ("target"), 2^256/difficulty
-//   result[3], hex encoded block number
+// expired returns whether a submitted hash rate is older than the allowed TTL.
+func (s *remoteSealer) expired(rate hashrate) bool {
+	return s.ethash.clock.Now().Sub(rate.seen) > remoteRateTTL
+}
+
+// medianRate returns the median of the given hash rate submissions, preferring
+// the lower middle value for an even count to err on the side of caution.
+func medianRate(rates []uint64) uint64 {
+	sorted := append([]uint64(nil), rates...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)-1)/2]
+}
+
+// makeWork creates a work package for external miner, see WorkPackage.
 func (s *remoteSealer) makeWork(block *types.Block) {
-	hash := s.ethash.SealHash(block.Header())
-	s.currentWork[0] = hash.Hex()
-	s.currentWork[1] = common.BytesToHash(SeedHash(block.NumberU64())).Hex()
-	s.currentWork[2] = common.BytesToHash(new(big.Int).Div(two256, block.Difficulty()).Bytes()).Hex()
-	s.currentWork[3] = hexutil.EncodeBig(block.Number())
+	// Work building on a different parent obsoletes all previous work
+	s.currentClean = s.currentBlock == nil || s.currentBlock.ParentHash() != block.ParentHash()
+	if s.currentClean {
+		atomic.AddUint64(&s.workEpoch, 1)
+	}
+	s.currentWork = s.ethash.WorkPackage(block.Header())
+	hash := common.HexToHash(s.currentWork[0])
 
 	// Trace the seal work fetched by remote sealer.
 	s.currentBlock = block
+	s.currentTime = time.Now()
 	s.works[hash] = block
 }
 
+// WorkPackage creates the work package external miners would receive for the
+// given header.
+//
+// The work package consists of 4 strings:
+//   result[0], 32 bytes hex encoded header pow-hash (seal hash)
+//   result[1], 32 bytes hex encoded seed hash used for DAG
+//   result[2], 32 bytes hex encoded boundary condition ("target"), 2^256/difficulty
+//   result[3], hex encoded block number
+//
+// If CompactTarget is configured, result[2] is instead the 4 bytes hex encoded
+// compact form of the target.
+func (ethash *Ethash) WorkPackage(header *types.Header) [4]string {
+	target := common.BytesToHash(ethash.calcTarget(header).Bytes()).Hex()
+	if ethash.config.CompactTarget {
+		var nBits [4]byte
+		binary.BigEndian.PutUint32(nBits[:], TargetToCompact(ethash.calcTarget(header)))
+		target = hexutil.Encode(nBits[:])
+	}
+	return [4]string{
+		ethash.SealHash(header).Hex(),
+		common.BytesToHash(SeedHash(header.Number.Uint64())).Hex(),
+		target,
+		hexutil.EncodeBig(header.Number),
+	}
+}
+
+// ParseWork decodes a work package as created by WorkPackage, returning the seal
+// hash, target and block number it carries. The hashes must be 32 bytes of hex,
+// the target either 32 bytes or its 4 bytes compact form, and the seed hash must
+// match the block number's epoch. Invalid packages are rejected with an error
+// wrapping ErrMalformedWork.
+func ParseWork(work [4]string) (common.Hash, *big.Int, uint64, error) {
+	var fields [3]common.Hash
+	for i, name := range []string{"seal hash", "seed hash", "target"} {
+		blob, err := hexutil.Decode(work[i])
+		if err != nil {
+			return common.Hash{}, nil, 0, fmt.Errorf("%w: %s: %v", ErrMalformedWork, name, err)
+		}
+		if i == 2 && len(blob) == 4 {
+			// Compact targets may exceed 32 bytes, which is as easy as the largest
+			// 32 byte target, since no PoW result can be larger
+			target := CompactToTarget(binary.BigEndian.Uint32(blob))
+			if target.Cmp(two256) >= 0 {
+				target.Sub(two256, big1)
+			}
+			blob = common.LeftPadBytes(target.Bytes(), common.HashLength)
+		}
+		if len(blob) != common.HashLength {
+			return common.Hash{}, nil, 0, fmt.Errorf("%w: %s: have %d bytes, want %d", ErrMalformedWork, name, len(blob), common.HashLength)
+		}
+		fields[i] = common.BytesToHash(blob)
+	}
+	number, err := hexutil.DecodeUint64(work[3])
+	if err != nil {
+		return common.Hash{}, nil, 0, fmt.Errorf("%w: block number: %v", ErrMalformedWork, err)
+	}
+	if seed := common.BytesToHash(SeedHash(number)); fields[1] != seed {
+		return common.Hash{}, nil, 0, fmt.Errorf("%w: seed hash %x doesn't match block %d", ErrMalformedWork, fields[1], number)
+	}
+	return fields[0], fields[2].Big(), number, nil
+}
+
 // notifyWork notifies all the specified mining endpoints of the availability of
-// new work to be processed.
+// new work to be processed. If work was pushed less than MinNotifyInterval ago,
+// the push is deferred until the interval expires, coalescing any work arriving
+// in the meantime.
 func (s *remoteSealer) notifyWork() {
-	work := s.currentWork
+	if interval := s.ethash.config.MinNotifyInterval; interval > 0 {
+		if wait := interval - time.Since(s.lastNotify); wait > 0 {
+			s.notifyClean = s.notifyClean || s.currentClean
+			if s.notifyTimer == nil {
+				s.notifyTimer = time.NewTimer(wait)
+			}
+			return
+		}
+	}
+	s.pushWork()
+}
+
+// pushWork sends the current work package to all the notify URLs. If enabled
+// via NotifyCleanFlag, the work package is followed by a "clean jobs" flag
+// signalling whether all previous work became stale.
+func (s *remoteSealer) pushWork() {
+	work, clean := s.currentWork, s.currentClean || s.notifyClean
+	s.notifyClean = false
+
 	blob, _ := json.Marshal(work)
+	if s.ethash.config.NotifyCleanFlag {
+		blob, _ = json.Marshal([]interface{}{work[0], work[1], work[2], work[3], clean})
+	}
+
+	s.notifyLock.RLock()
+	defer s.notifyLock.RUnlock()
+
+	if s.notifyPaused {
+		return
+	}
+	s.lastNotify = time.Now()
 	s.reqWG.Add(len(s.notifyURLs))
 	for _, url := range s.notifyURLs {
 		go s.sendNotification(s.notifyCtx, url, blob, work)
@@ -378,7 +1193,7 @@ func (s *remoteSealer) sendNotification(ctx context.Context, url string, json []
 	req = req.WithContext(ctx)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.client.Do(req)
 	if err != nil {
 		s.ethash.config.Log.Warn("Failed to notify remote miner", "err", err)
 	} else {
@@ -387,19 +1202,30 @@ func (s *remoteSealer) sendNotification(ctx context.Context, url string, json []
 	}
 }
 
-// submitWork verifies the submitted pow solution, returning
-// whether the solution was accepted or not (not can be both a bad pow as well as
-// any other error, like no pending work or stale mining result).
-func (s *remoteSealer) submitWork(nonce types.BlockNonce, mixDigest common.Hash, sealhash common.Hash) bool {
+// submitWork verifies the submitted pow solution, returning an error if the
+// solution was not accepted (which can be both a bad pow as well as any other
+// issue, like no pending work or stale mining result).
+func (s *remoteSealer) submitWork(number *uint64, nonce types.BlockNonce, mixDigest common.Hash, sealhash common.Hash) error {
 	if s.currentBlock == nil {
 		s.ethash.config.Log.Error("Pending work without block", "sealhash", sealhash)
-		return false
+		return errNoMiningWork
 	}
 	// Make sure the work submitted is present
 	block := s.works[sealhash]
 	if block == nil {
 		s.ethash.config.Log.Warn("Work submitted but none pending", "sealhash", sealhash, "curnumber", s.currentBlock.NumberU64())
-		return false
+		return ErrInvalidSealHash
+	}
+	// If the miner told which block the solution is for, make sure it matches
+	if number != nil && *number != block.NumberU64() {
+		s.ethash.config.Log.Warn("Work submitted for mismatching block number", "sealhash", sealhash, "number", *number, "want", block.NumberU64())
+		return ErrWorkNumberMismatch
+	}
+	// Don't reprocess solutions which were already accepted
+	key := submittedWork{sealhash: sealhash, nonce: nonce}
+	if s.submitted.Contains(key) {
+		s.ethash.config.Log.Debug("Duplicate work submitted", "sealhash", sealhash, "nonce", nonce)
+		return ErrDuplicateWork
 	}
 	// Verify the correctness of submitted result.
 	header := block.Header()
@@ -408,15 +1234,16 @@ func (s *remoteSealer) submitWork(nonce types.BlockNonce, mixDigest common.Hash,
 
 	start := time.Now()
 	if !s.noverify {
+		// The reason of rejection is returned to the submitter
 		if err := s.ethash.verifySeal(nil, header, true); err != nil {
 			s.ethash.config.Log.Warn("Invalid proof-of-work submitted", "sealhash", sealhash, "elapsed", common.PrettyDuration(time.Since(start)), "err", err)
-			return false
+			return err
 		}
 	}
 	// Make sure the result channel is assigned.
 	if s.results == nil {
 		s.ethash.config.Log.Warn("Ethash result channel is empty, submitted mining result is rejected")
-		return false
+		return errInvalidSealResult
 	}
 	s.ethash.config.Log.Trace("Verified correct proof-of-work", "sealhash", sealhash, "elapsed", common.PrettyDuration(time.Since(start)))
 
@@ -428,13 +1255,14 @@ func (s *remoteSealer) submitWork(nonce types.BlockNonce, mixDigest common.Hash,
 		select {
 		case s.results <- solution:
 			s.ethash.config.Log.Debug("Work submitted is acceptable", "number", solution.NumberU64(), "sealhash", sealhash, "hash", solution.Hash())
-			return true
+			s.submitted.Add(key, struct{}{})
+			return nil
 		default:
 			s.ethash.config.Log.Warn("Sealing result is not read by miner", "mode", "remote", "sealhash", sealhash)
-			return false
+			return errInvalidSealResult
 		}
 	}
 	// The submitted block is too old to accept, drop it.
 	s.ethash.config.Log.Warn("Work submitted is too old", "number", solution.NumberU64(), "sealhash", sealhash, "hash", solution.Hash())
-	return false
+	return ErrStaleWork
 }
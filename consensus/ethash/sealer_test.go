@@ -17,18 +17,30 @@
 package ethash
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/core/rawdb"
+	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/internal/testlog"
 	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/rpc"
 )
 
 // Tests whether remote HTTP servers are correctly notified of new work.
@@ -74,6 +86,1017 @@ func TestRemoteNotify(t *testing.T) {
 	}
 }
 
+// Tests that work packages are created correctly for arbitrary headers and match
+// the ones handed out to remote miners.
+func TestWorkPackage(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	want := [4]string{
+		ethash.SealHash(header).Hex(),
+		"0x0000000000000000000000000000000000000000000000000000000000000000",
+		"0x028f5c28f5c28f5c28f5c28f5c28f5c28f5c28f5c28f5c28f5c28f5c28f5c28f",
+		"0x1",
+	}
+	if have := ethash.WorkPackage(header); have != want {
+		t.Errorf("work package mismatch: have %v, want %v", have, want)
+	}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), nil, nil)
+	if have, err := (&API{ethash}).GetWork(); err != nil || have != want {
+		t.Errorf("remote work mismatch: have %v/%v, want %v", have, err, want)
+	}
+}
+
+// Tests that work packages can be parsed back into their fields, and that
+// malformed ones are rejected.
+func TestParseWork(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	header := &types.Header{Number: big.NewInt(epochLength + 1), Difficulty: big.NewInt(100)}
+	work := ethash.WorkPackage(header)
+
+	sealhash, target, number, err := ParseWork(work)
+	if err != nil {
+		t.Fatalf("failed to parse work: %v", err)
+	}
+	if sealhash != ethash.SealHash(header) {
+		t.Errorf("seal hash mismatch: have %x, want %x", sealhash, ethash.SealHash(header))
+	}
+	if target.Cmp(CalcTarget(header)) != 0 {
+		t.Errorf("target mismatch: have %x, want %x", target, CalcTarget(header))
+	}
+	if number != header.Number.Uint64() {
+		t.Errorf("number mismatch: have %d, want %d", number, header.Number.Uint64())
+	}
+	// Corrupt each field in turn and ensure the package is rejected
+	tests := []func(work *[4]string){
+		func(work *[4]string) { work[0] = work[0][:len(work[0])-2] },
+		func(work *[4]string) { work[0] = work[0][2:] },
+		func(work *[4]string) { work[1] = common.BytesToHash(SeedHash(1)).Hex() },
+		func(work *[4]string) { work[2] = "0xzz" },
+		func(work *[4]string) { work[3] = "1" },
+	}
+	for i, corrupt := range tests {
+		malformed := work
+		corrupt(&malformed)
+		if _, _, _, err := ParseWork(malformed); !errors.Is(err, ErrMalformedWork) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, ErrMalformedWork)
+		}
+	}
+}
+
+// Tests that work packages optionally carry the target in compact form, which
+// is decoded back when parsing them.
+func TestCompactWorkPackage(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.config.CompactTarget = true
+
+	header := NewTestHeader(1, 1e9)
+	work := ethash.WorkPackage(header)
+	if want := hexutil.Encode([]byte{0x1d, 0x04, 0x4b, 0x82}); work[2] != want {
+		t.Fatalf("compact target mismatch: have %s, want %s", work[2], want)
+	}
+	_, target, _, err := ParseWork(work)
+	if err != nil {
+		t.Fatalf("failed to parse work: %v", err)
+	}
+	if want := CompactToTarget(TargetToCompact(CalcTarget(header))); target.Cmp(want) != 0 {
+		t.Errorf("target mismatch: have %x, want %x", target, want)
+	}
+	// Compact targets beyond 256 bits are capped instead of rejected
+	if _, target, _, err = ParseWork(ethash.WorkPackage(NewTestHeader(1, 1))); err != nil || target.Cmp(new(big.Int).Sub(two256, big1)) != 0 {
+		t.Errorf("difficulty 1 target mismatch: have %x (%v), want %x", target, err, new(big.Int).Sub(two256, big1))
+	}
+}
+
+// Tests that work notifications can be paused without affecting local sealing.
+func TestRemoteNotifyPause(t *testing.T) {
+	sink := make(chan [4]string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var work [4]string
+		if err := json.NewDecoder(req.Body).Decode(&work); err != nil {
+			t.Errorf("failed to unmarshal miner notification: %v", err)
+		}
+		sink <- work
+	}))
+	defer server.Close()
+
+	ethash := NewTester([]string{server.URL}, false)
+	defer ethash.Close()
+	ethash.SetThreads(1)
+	api := &PrivateAPI{ethash}
+
+	// Pause notifications and ensure local sealing still works
+	if err := api.SetNotifyEnabled(false); err != nil {
+		t.Fatalf("failed to pause notifications: %v", err)
+	}
+	results := make(chan *types.Block, 1)
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	select {
+	case <-results:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("local sealing timed out")
+	}
+	select {
+	case <-sink:
+		t.Errorf("notification sent while paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+	// Resume notifications and ensure new work is pushed again
+	if err := api.SetNotifyEnabled(true); err != nil {
+		t.Fatalf("failed to resume notifications: %v", err)
+	}
+	header = &types.Header{Number: big.NewInt(2), Difficulty: big.NewInt(1)}
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	select {
+	case work := <-sink:
+		if want := ethash.SealHash(header).Hex(); work[0] != want {
+			t.Errorf("work packet hash mismatch: have %s, want %s", work[0], want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("notification timed out after resuming")
+	}
+}
+
+// Tests that the notified endpoints can be swapped at runtime.
+func TestRemoteNotifyURLsUpdate(t *testing.T) {
+	// Start two web servers capturing notifications
+	startServer := func(sink chan [4]string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var work [4]string
+			if err := json.NewDecoder(req.Body).Decode(&work); err != nil {
+				t.Errorf("failed to unmarshal miner notification: %v", err)
+			}
+			sink <- work
+		}))
+	}
+	oldSink, newSink := make(chan [4]string, 1), make(chan [4]string, 1)
+	oldServer, newServer := startServer(oldSink), startServer(newSink)
+	defer oldServer.Close()
+	defer newServer.Close()
+
+	ethash := NewTester([]string{oldServer.URL}, false)
+	defer ethash.Close()
+	api, private := &API{ethash}, &PrivateAPI{ethash}
+
+	// Invalid endpoints must be rejected without altering the current ones
+	if err := private.SetNotifyURLs([]string{newServer.URL, "localhost:8545"}); err == nil {
+		t.Fatalf("invalid notify URL accepted")
+	}
+	if have := api.GetNotifyURLs(); len(have) != 1 || have[0] != oldServer.URL {
+		t.Fatalf("notify URLs mismatch: have %v, want %v", have, []string{oldServer.URL})
+	}
+	// Push work to the original endpoint, then swap it out and push again
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), nil, nil)
+	select {
+	case <-oldSink:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("original endpoint notification timed out")
+	}
+	if err := private.SetNotifyURLs([]string{newServer.URL}); err != nil {
+		t.Fatalf("failed to set notify URLs: %v", err)
+	}
+	header = &types.Header{Number: big.NewInt(2), Difficulty: big.NewInt(100)}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), nil, nil)
+	select {
+	case work := <-newSink:
+		if want := ethash.SealHash(header).Hex(); work[0] != want {
+			t.Errorf("work packet hash mismatch: have %s, want %s", work[0], want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("new endpoint notification timed out")
+	}
+	select {
+	case <-oldSink:
+		t.Errorf("removed endpoint still notified")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Tests that remote solutions are rejected with the reason of the failed proof-of-work.
+func TestRemoteSolutionVerification(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	// Push work which no nonce can realistically satisfy
+	hard := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 200)}
+	results := make(chan *types.Block, 1)
+	ethash.Seal(nil, types.NewBlockWithHeader(hard), results, nil)
+
+	sealhash := ethash.SealHash(hard)
+	digest, _ := ethash.hashimoto(hard.Number.Uint64(), sealhash, 1, false)
+
+	if err := ethash.SubmitWork(types.EncodeNonce(1), sealhash, common.HexToHash("deadbeef")); err != errInvalidMixDigest {
+		t.Errorf("wrong digest error mismatch: have %v, want %v", err, errInvalidMixDigest)
+	}
+	if err := ethash.SubmitWork(types.EncodeNonce(1), sealhash, common.BytesToHash(digest)); err != errInvalidPoW {
+		t.Errorf("above target error mismatch: have %v, want %v", err, errInvalidPoW)
+	}
+	// Push work which any nonce satisfies
+	easy := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	ethash.Seal(nil, types.NewBlockWithHeader(easy), results, nil)
+
+	sealhash = ethash.SealHash(easy)
+	digest, _ = ethash.hashimoto(easy.Number.Uint64(), sealhash, 1, false)
+
+	if err := ethash.SubmitWork(types.EncodeNonce(1), sealhash, common.BytesToHash(digest)); err != nil {
+		t.Errorf("valid solution rejected: %v", err)
+	}
+}
+
+// Tests that blocking sealing returns a valid sealed block.
+func TestSealBlocking(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	block, err := ethash.SealBlocking(ctx, nil, types.NewBlockWithHeader(header))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if err := ethash.VerifySeal(nil, block.Header()); err != nil {
+		t.Fatalf("unexpected verification error: %v", err)
+	}
+	// The blocking seal must not be handed out to remote miners
+	if _, err := (&API{ethash}).GetWork(); !errors.Is(err, ErrNoMiningWork) {
+		t.Errorf("remote work error mismatch: have %v, want %v", err, ErrNoMiningWork)
+	}
+	// Sealing without any local threads must respect the context
+	ethash.SetThreads(-1)
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := ethash.SealBlocking(ctx, nil, types.NewBlockWithHeader(header)); err != context.DeadlineExceeded {
+		t.Errorf("idle seal error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// Tests that mining threads can be pinned to CPUs, and that sealing still works
+// with thread affinity configured.
+func TestThreadAffinity(t *testing.T) {
+	if !affinitySupported {
+		t.Skip("thread affinity not supported on " + runtime.GOOS)
+	}
+	errc := make(chan error)
+	go func() { errc <- pinThread(0) }()
+	if err := <-errc; err != nil {
+		t.Fatalf("failed to pin thread: %v", err)
+	}
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.config.ThreadAffinity = []int{0}
+	ethash.SetThreads(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	block, err := ethash.SealBlocking(ctx, nil, types.NewBlockWithHeader(NewTestHeader(1, 100)))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if err := ethash.VerifySeal(nil, block.Header()); err != nil {
+		t.Fatalf("unexpected verification error: %v", err)
+	}
+}
+
+// Tests that invalid CPU indices are dropped from the thread affinity up front,
+// instead of failing to pin every mining thread assigned to them.
+func TestInvalidThreadAffinity(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, ThreadAffinity: []int{-1, 0, runtime.NumCPU()}}, nil, false)
+	defer ethash.Close()
+
+	if have := ethash.config.ThreadAffinity; !reflect.DeepEqual(have, []int{0}) {
+		t.Errorf("thread affinity mismatch: have %v, want %v", have, []int{0})
+	}
+	ethash = New(Config{PowMode: ModeTest, ThreadAffinity: []int{-1}}, nil, false)
+	defer ethash.Close()
+
+	if have := ethash.config.ThreadAffinity; have != nil {
+		t.Errorf("thread affinity mismatch: have %v, want none", have)
+	}
+}
+
+// Tests that subscribers are notified of found and verified seals, and that a
+// cancelled subscription is closed.
+func TestSubscribeEvents(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(1)
+
+	events, cancel := ethash.SubscribeEvents(16)
+	defer cancel()
+
+	header := NewTestHeader(1, 1)
+	ctx, cancelSeal := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelSeal()
+	block, err := ethash.SealBlocking(ctx, nil, types.NewBlockWithHeader(header))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	ethash.VerifySeal(nil, block.Header())
+
+	for _, want := range []EventKind{SealFound, SealVerified} {
+		select {
+		case event := <-events:
+			if event.Kind != want {
+				t.Fatalf("event kind mismatch: have %v, want %v", event.Kind, want)
+			}
+			if event.SealHash != ethash.SealHash(header) || event.Nonce != block.Nonce() {
+				t.Errorf("%v: event mismatch: have sealhash %x nonce %d, want sealhash %x nonce %d", want, event.SealHash, event.Nonce, ethash.SealHash(header), block.Nonce())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%v event not delivered", want)
+		}
+	}
+	cancel()
+	if _, ok := <-events; ok {
+		t.Errorf("event delivered after cancellation")
+	}
+}
+
+// Tests that seal operations are tracked as jobs, which can be cancelled one by
+// one without affecting the others.
+func TestSealJobs(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(1)
+
+	var headers []*types.Header
+	for i := int64(1); i <= 2; i++ {
+		header := NewTestHeader(i, 1)
+		header.Difficulty = new(big.Int).Lsh(big.NewInt(1), 200)
+		headers = append(headers, header)
+		if err := ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block), nil); err != nil {
+			t.Fatalf("failed to seal block %d: %v", i, err)
+		}
+	}
+	jobs := ethash.ListJobs()
+	if len(jobs) != 2 {
+		t.Fatalf("job count mismatch: have %d, want 2", len(jobs))
+	}
+	for i, job := range jobs {
+		if job.SealHash != ethash.SealHash(headers[i]) || job.Number != uint64(i+1) {
+			t.Errorf("job %d mismatch: have sealhash %x number %d, want sealhash %x number %d", i, job.SealHash, job.Number, ethash.SealHash(headers[i]), i+1)
+		}
+	}
+	if err := ethash.CancelJob(jobs[0].ID); err != nil {
+		t.Fatalf("failed to cancel job: %v", err)
+	}
+	if err := ethash.CancelJob(jobs[0].ID); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("repeated cancel error mismatch: have %v, want %v", err, ErrUnknownJob)
+	}
+	for start := time.Now(); atomic.LoadInt32(&ethash.sealing) != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 3*time.Second {
+			t.Fatalf("seal operation count mismatch: have %d, want 1", atomic.LoadInt32(&ethash.sealing))
+		}
+	}
+	if remaining := ethash.ListJobs(); len(remaining) != 1 || remaining[0] != jobs[1] {
+		t.Errorf("remaining jobs mismatch: have %v, want %v", remaining, jobs[1:])
+	}
+}
+
+// Tests that mining threads are throttled one by one while the hash rate is above
+// the target, and resumed again once it drops below.
+func TestThrottleThreads(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(4)
+	ethash.config.TargetHashrate = 100
+
+	for _, want := range []int{3, 2, 1, 1} {
+		ethash.throttleStep(1000)
+		if have := ethash.ActiveThreads(); have != want {
+			t.Fatalf("active threads mismatch over target: have %d, want %d", have, want)
+		}
+	}
+	for _, want := range []int{2, 3, 4, 4} {
+		ethash.throttleStep(10)
+		if have := ethash.ActiveThreads(); have != want {
+			t.Fatalf("active threads mismatch under target: have %d, want %d", have, want)
+		}
+	}
+	if limit := atomic.LoadInt32(&ethash.throttle); limit != 0 {
+		t.Errorf("throttle mismatch with all threads active: have %d, want 0", limit)
+	}
+}
+
+// Tests that a tester with a difficulty floor doesn't seal trivial blocks right
+// away, and that such a seal can still be aborted.
+func TestTesterMinDifficulty(t *testing.T) {
+	ethash := NewTesterWithDifficulty(nil, false, new(big.Int).Lsh(big.NewInt(1), 128))
+	defer ethash.Close()
+	ethash.SetThreads(1)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	results, stop := make(chan *types.Block), make(chan struct{})
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, stop); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	select {
+	case block := <-results:
+		t.Fatalf("trivial block sealed despite difficulty floor: nonce %d", block.Nonce())
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(stop)
+	for start := time.Now(); ethash.IsSealing(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 3*time.Second {
+			t.Fatal("engine still sealing after abort")
+		}
+	}
+}
+
+// Tests that blocks with a scripted difficulty are prepared and sealed at that
+// difficulty, while other blocks keep their own.
+func TestDifficultyByBlock(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.config.DifficultyByBlock = map[uint64]*big.Int{2: big.NewInt(50), 3: big.NewInt(200)}
+	ethash.SetThreads(1)
+
+	for number, want := range map[int64]int64{1: 100, 2: 50, 3: 200, 4: 100} {
+		header := NewTestHeader(number, 100)
+		if _, ok := ethash.config.DifficultyByBlock[uint64(number)]; ok {
+			header.Difficulty = ethash.CalcDifficulty(nil, header.Time, NewTestHeader(number-1, 100))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		block, err := ethash.SealBlocking(ctx, nil, types.NewBlockWithHeader(header))
+		cancel()
+		if err != nil {
+			t.Fatalf("block %d: failed to seal: %v", number, err)
+		}
+		if block.Difficulty().Int64() != want {
+			t.Errorf("block %d: difficulty mismatch: have %v, want %d", number, block.Difficulty(), want)
+		}
+		if err := ethash.VerifySeal(nil, block.Header()); err != nil {
+			t.Errorf("block %d: unexpected verification error: %v", number, err)
+		}
+	}
+	// Blocks carrying any other difficulty are sealed as they are
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	block, err := ethash.SealBlocking(ctx, nil, types.NewBlockWithHeader(NewTestHeader(2, 100)))
+	if err != nil {
+		t.Fatalf("failed to seal unprepared block: %v", err)
+	}
+	if block.Difficulty().Int64() != 100 {
+		t.Errorf("unprepared block difficulty mismatch: have %v, want 100", block.Difficulty())
+	}
+}
+
+// Tests that local mining idles while the mining gate is closed and resumes
+// once it opens.
+func TestMineGate(t *testing.T) {
+	var open int32
+	ethash := New(Config{PowMode: ModeTest, MineGate: func() bool { return atomic.LoadInt32(&open) == 1 }}, nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(1)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	results, stop := make(chan *types.Block), make(chan struct{})
+	defer close(stop)
+
+	// Seal a trivial block with the gate closed, nothing should be found
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, stop); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	select {
+	case <-results:
+		t.Fatal("block sealed while mining gate closed")
+	case <-time.After(3 * mineGateRecheck):
+	}
+	// Open the gate and ensure the block gets sealed
+	atomic.StoreInt32(&open, 1)
+	select {
+	case <-results:
+	case <-time.After(3 * time.Second):
+		t.Fatal("block not sealed after mining gate opened")
+	}
+	// Close the gate again and ensure mining stops
+	atomic.StoreInt32(&open, 0)
+	header.Number = big.NewInt(2)
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, stop); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	select {
+	case <-results:
+		t.Fatal("block sealed after mining gate closed")
+	case <-time.After(3 * mineGateRecheck):
+	}
+}
+
+// Tests that the nonce search progress of the local miners is reported and that
+// threads running out of nonces restart from a fresh seed instead of wrapping.
+func TestNonceProgress(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(1)
+
+	if progress := ethash.NonceProgress(); progress != nil {
+		t.Fatalf("progress reported before sealing: %v", progress)
+	}
+	// Resume the search right before the end of the nonce space
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 200)}
+	start := uint64(math.MaxUint64 - 100)
+	ethash.resume = &nonceCursors{sealhash: ethash.SealHash(header), nonces: []uint64{start}}
+
+	results, stop := make(chan *types.Block), make(chan struct{})
+	defer close(stop)
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, stop); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	// Wait for the thread to exhaust the nonce space and reseed
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		progress := ethash.NonceProgress()
+		if len(progress) != 1 {
+			t.Fatalf("progress thread count mismatch: have %d, want %d", len(progress), 1)
+		}
+		if uint64(progress[0].Seed) != start {
+			if uint64(progress[0].Nonce) >= start {
+				t.Fatalf("nonce wrapped around: %v", progress[0])
+			}
+			if progress[0].Scanned+progress[0].Remaining != hexutil.Uint64(math.MaxUint64-progress[0].Seed) {
+				t.Fatalf("inconsistent progress: %v", progress[0])
+			}
+			break
+		}
+		if uint64(progress[0].Remaining) != math.MaxUint64-uint64(progress[0].Nonce) {
+			t.Fatalf("remaining nonces mismatch: %v", progress[0])
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("thread not reseeded: %v", progress[0])
+		}
+	}
+}
+
+// Tests that the progress callback is invoked with the number of tried nonces
+// while sealing.
+func TestProgressCallback(t *testing.T) {
+	reports := make(chan uint64, 1)
+	ethash := NewTesterWithDifficulty(nil, false, new(big.Int).Lsh(big.NewInt(1), 128))
+	defer ethash.Close()
+	ethash.config.ProgressCallback = func(tried uint64) {
+		select {
+		case reports <- tried:
+		default:
+		}
+	}
+	ethash.SetThreads(2)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block), stop); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	// Mining threads publish progress in large batches of nonces, which may take
+	// a while on slow (or instrumented) builds. Publish some directly instead.
+	ethash.lock.Lock()
+	cursors := ethash.cursors
+	ethash.lock.Unlock()
+	atomic.AddUint64(&cursors.tried, 1)
+
+	select {
+	case tried := <-reports:
+		if tried == 0 {
+			t.Error("progress reported without any tried nonces")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no progress reported")
+	}
+}
+
+// Tests that progress is reported with increasing numbers of tried nonces, only
+// if nonces were tried since the previous report, until sealing is aborted.
+func TestReportProgress(t *testing.T) {
+	var (
+		cursors = new(nonceCursors)
+		reports = make(chan uint64, 16)
+		abort   = make(chan struct{})
+		done    = make(chan struct{})
+	)
+	go func() {
+		reportProgress(cursors, func(tried uint64) { reports <- tried }, abort)
+		close(done)
+	}()
+	for _, tried := range []uint64{10, 25} {
+		atomic.StoreUint64(&cursors.tried, tried)
+		select {
+		case have := <-reports:
+			if have != tried {
+				t.Errorf("progress mismatch: have %d, want %d", have, tried)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("progress %d not reported", tried)
+		}
+	}
+	select {
+	case have := <-reports:
+		t.Errorf("unchanged progress reported: %d", have)
+	case <-time.After(3 * progressInterval):
+	}
+	close(abort)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("progress still reported after abort")
+	}
+}
+
+// Tests that all seal operations in progress can be aborted with a single call.
+func TestAbortSealing(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(2)
+
+	results, stop := make(chan *types.Block), make(chan struct{})
+	defer close(stop)
+	for i := int64(1); i <= 2; i++ {
+		header := &types.Header{Number: big.NewInt(i), Difficulty: new(big.Int).Lsh(big.NewInt(1), 200)}
+		if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, stop); err != nil {
+			t.Fatalf("failed to seal block %d: %v", i, err)
+		}
+	}
+	if !ethash.IsSealing() {
+		t.Fatal("engine not sealing")
+	}
+	done := make(chan struct{})
+	go func() {
+		ethash.AbortSealing()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("sealing not aborted in time")
+	}
+	if ethash.IsSealing() {
+		t.Error("engine still sealing after abort")
+	}
+	// Ensure sealing can be resumed afterwards
+	header := &types.Header{Number: big.NewInt(3), Difficulty: big.NewInt(1)}
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, stop); err != nil {
+		t.Fatalf("failed to seal block after abort: %v", err)
+	}
+	select {
+	case <-results:
+	case <-time.After(3 * time.Second):
+		t.Fatal("block not sealed after abort")
+	}
+}
+
+// Tests that solutions found by the local miner are logged with their details.
+func TestSealLog(t *testing.T) {
+	records := make(chan *log.Record, 1)
+	logger := log.New()
+	logger.SetHandler(log.FuncHandler(func(r *log.Record) error {
+		if r.Msg == "Ethash nonce found" {
+			select {
+			case records <- r:
+			default:
+			}
+		}
+		return nil
+	}))
+	ethash := New(Config{PowMode: ModeTest, Log: logger}, nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(1)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	if _, err := ethash.SealBlocking(context.Background(), nil, types.NewBlockWithHeader(header)); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	var record *log.Record
+	select {
+	case record = <-records:
+	default:
+		t.Fatal("sealed block not logged")
+	}
+	if record.Lvl != log.LvlInfo {
+		t.Errorf("log level mismatch: have %v, want %v", record.Lvl, log.LvlInfo)
+	}
+	fields := make(map[string]interface{})
+	for i := 0; i+1 < len(record.Ctx); i += 2 {
+		fields[record.Ctx[i].(string)] = record.Ctx[i+1]
+	}
+	for _, key := range []string{"miner", "number", "nonce", "sealhash", "elapsed", "attempts"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("log field %q missing: %v", key, record.Ctx)
+		}
+	}
+	if sealhash := fields["sealhash"]; sealhash != ethash.SealHash(header) {
+		t.Errorf("logged seal hash mismatch: have %v, want %v", sealhash, ethash.SealHash(header))
+	}
+}
+
+// Tests that a configured random source deterministically seeds the nonces the
+// local mining threads start searching from.
+func TestRandSource(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 200)}
+
+	seed := func() uint64 {
+		ethash := New(Config{PowMode: ModeTest, RandSource: bytes.NewReader(bytes.Repeat([]byte{0x42}, 32))}, nil, false)
+		defer ethash.Close()
+		ethash.SetThreads(1)
+
+		results, stop := make(chan *types.Block), make(chan struct{})
+		defer close(stop)
+		if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, stop); err != nil {
+			t.Fatalf("failed to seal block: %v", err)
+		}
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if progress := ethash.NonceProgress(); len(progress) == 1 && progress[0].Seed != 0 {
+				return uint64(progress[0].Seed)
+			}
+		}
+		t.Fatal("mining thread not started")
+		return 0
+	}
+	if first, second := seed(), seed(); first != second {
+		t.Fatalf("starting nonce mismatch: have %d, want %d", second, first)
+	}
+}
+
+// Tests that solutions are buffered instead of dropped if the consumer is not
+// ready to receive them.
+func TestResultsBuffer(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, ResultsBuffer: 4}, nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	results, stop := make(chan *types.Block), make(chan struct{})
+	defer close(stop)
+	ethash.Seal(nil, types.NewBlockWithHeader(header), results, stop)
+
+	// Submit a few solutions without anyone reading the results
+	api, sealhash := &API{ethash}, ethash.SealHash(header)
+	for i := uint64(0); i < 3; i++ {
+		if !api.SubmitWork(types.EncodeNonce(i), sealhash, common.Hash{}) {
+			t.Fatalf("solution %d rejected", i)
+		}
+	}
+	// Consume the solutions and ensure none were lost
+	for i := uint64(0); i < 3; i++ {
+		select {
+		case block := <-results:
+			if block.Nonce() != i {
+				t.Errorf("solution %d nonce mismatch: have %d, want %d", i, block.Nonce(), i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("solution %d not delivered", i)
+		}
+	}
+}
+
+// Tests that the result forwarder of a seal without a stop channel is retired
+// once a newer seal takes over, after delivering the results already buffered.
+func TestResultsBufferRetire(t *testing.T) {
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+
+	results := make(chan *types.Block)
+	first := ethash.bufferResults(results, 2, nil)
+	first <- types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	ethash.bufferResults(results, 2, nil)
+
+	select {
+	case block := <-results:
+		if block.NumberU64() != 1 {
+			t.Errorf("buffered result mismatch: have block %d, want 1", block.NumberU64())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("buffered result not delivered after retirement")
+	}
+	// Give the retired forwarder time to exit, nothing written later is forwarded
+	time.Sleep(50 * time.Millisecond)
+	first <- types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2)})
+	select {
+	case block := <-results:
+		t.Fatalf("result forwarded after retirement: block %d", block.NumberU64())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Tests that a buffered result nobody reads is dropped once the send timeout
+// expires, instead of being delivered whenever the consumer shows up again.
+func TestResultSendTimeout(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, ResultsBuffer: 1, ResultSendTimeout: 100 * time.Millisecond}, nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	results := make(chan *types.Block)
+	ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+
+	if !(&API{ethash}).SubmitWork(types.EncodeNonce(1), ethash.SealHash(header), common.Hash{}) {
+		t.Fatal("solution rejected")
+	}
+	// Wait well past the send timeout, the result must not be delivered any more
+	time.Sleep(3 * ethash.config.ResultSendTimeout)
+	select {
+	case block := <-results:
+		t.Fatalf("dropped result delivered: nonce %d", block.Nonce())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Tests that the sealing status is reported while a seal is running and cleared
+// once it's aborted.
+func TestIsSealing(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(1)
+
+	if ethash.IsSealing() {
+		t.Fatal("engine reported sealing before any seal")
+	}
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 128)}
+	stop := make(chan struct{})
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block), stop); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if !ethash.IsSealing() {
+		t.Fatal("engine not reported sealing during a seal")
+	}
+	close(stop)
+	for start := time.Now(); ethash.IsSealing(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 3*time.Second {
+			t.Fatal("engine still reported sealing after abort")
+		}
+	}
+}
+
+// Tests that TrySeal refuses to start a seal while another one is in progress,
+// but starts one again after the previous was stopped.
+func TestTrySeal(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(1)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 128)}
+	stop := make(chan struct{})
+	if started, err := ethash.TrySeal(nil, types.NewBlockWithHeader(header), make(chan *types.Block), stop); err != nil || !started {
+		t.Fatalf("first seal not started: %v, %v", started, err)
+	}
+	if started, err := ethash.TrySeal(nil, types.NewBlockWithHeader(header), make(chan *types.Block), nil); err != nil || started {
+		t.Fatalf("overlapping seal started: %v, %v", started, err)
+	}
+	close(stop)
+	for start := time.Now(); ethash.IsSealing(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 3*time.Second {
+			t.Fatal("engine still sealing after abort")
+		}
+	}
+	stop = make(chan struct{})
+	defer close(stop)
+	if started, err := ethash.TrySeal(nil, types.NewBlockWithHeader(header), make(chan *types.Block), stop); err != nil || !started {
+		t.Fatalf("seal after abort not started: %v, %v", started, err)
+	}
+}
+
+// notifyRecorder is a stub HTTP transport capturing the work packages pushed by
+// the remote sealer without touching the network.
+type notifyRecorder struct {
+	sink chan [4]string
+}
+
+func (r *notifyRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	blob, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	var work [4]string
+	if err := json.Unmarshal(blob, &work); err != nil {
+		return nil, err
+	}
+	r.sink <- work
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+		Request:    req,
+	}, nil
+}
+
+// Tests that work notifications are delivered through a custom transport if
+// one is configured.
+func TestRemoteNotifyTransport(t *testing.T) {
+	recorder := &notifyRecorder{sink: make(chan [4]string, 2)}
+	ethash := New(Config{PowMode: ModeTest, NotifyTransport: recorder}, []string{"http://miner.invalid/notify"}, false)
+	defer ethash.Close()
+
+	// Push two distinct work packages and ensure both are recorded in order.
+	for i := 1; i <= 2; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), Difficulty: big.NewInt(100)}
+		ethash.Seal(nil, types.NewBlockWithHeader(header), nil, nil)
+
+		select {
+		case work := <-recorder.sink:
+			if want := ethash.SealHash(header).Hex(); work[0] != want {
+				t.Errorf("work %d hash mismatch: have %s, want %s", i, work[0], want)
+			}
+			if want := hexutil.EncodeBig(header.Number); work[3] != want {
+				t.Errorf("work %d number mismatch: have %s, want %s", i, work[3], want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("notification %d timed out", i)
+		}
+	}
+}
+
+// Tests that work pushed in quick succession is coalesced into few notifications
+// if a minimum notify interval is configured, the latest work being delivered.
+func TestMinNotifyInterval(t *testing.T) {
+	recorder := &notifyRecorder{sink: make(chan [4]string, 16)}
+	ethash := New(Config{PowMode: ModeTest, NotifyTransport: recorder, MinNotifyInterval: 200 * time.Millisecond}, []string{"http://miner.invalid/notify"}, false)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	var header *types.Header
+	for i := 1; i <= 10; i++ {
+		header = &types.Header{Number: big.NewInt(int64(i)), Difficulty: big.NewInt(100)}
+		ethash.Seal(nil, types.NewBlockWithHeader(header), nil, nil)
+	}
+	var works [][4]string
+	timeout := time.After(time.Second)
+	for done := false; !done; {
+		select {
+		case work := <-recorder.sink:
+			works = append(works, work)
+		case <-timeout:
+			done = true
+		}
+	}
+	if len(works) == 0 || len(works) > 3 {
+		t.Fatalf("notification count mismatch: have %d, want 1-3", len(works))
+	}
+	if want := ethash.SealHash(header).Hex(); works[len(works)-1][0] != want {
+		t.Errorf("last notified work mismatch: have %s, want %s", works[len(works)-1][0], want)
+	}
+}
+
+// Tests that new work on a different parent bumps the work epoch and is flagged
+// as obsoleting previous work in the notifications if enabled, whereas a resend
+// is not. Without the flag enabled, notifications carry the plain work package.
+func TestWorkEpoch(t *testing.T) {
+	for _, flag := range []bool{false, true} {
+		sink := make(chan []interface{}, 3)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var work []interface{}
+			if err := json.NewDecoder(req.Body).Decode(&work); err != nil {
+				t.Errorf("failed to unmarshal miner notification: %v", err)
+			}
+			sink <- work
+		}))
+		ethash := New(Config{PowMode: ModeTest, CachesInMem: 1, NotifyCleanFlag: flag}, []string{server.URL}, false)
+		api := &API{ethash}
+
+		if epoch := api.GetWorkEpoch(); epoch != 0 {
+			t.Fatalf("flag %v: initial epoch mismatch: have %d, want 0", flag, epoch)
+		}
+		// Push two work packages on different parents, then resend the last one
+		for i := 1; i <= 3; i++ {
+			clean, epoch := true, hexutil.Uint64(i)
+			if i <= 2 {
+				header := &types.Header{ParentHash: common.Hash{byte(i)}, Number: big.NewInt(int64(i)), Difficulty: big.NewInt(100)}
+				ethash.Seal(nil, types.NewBlockWithHeader(header), nil, nil)
+			} else {
+				if _, err := api.ResendWork(); err != nil {
+					t.Fatalf("flag %v: failed to resend work: %v", flag, err)
+				}
+				clean, epoch = false, 2
+			}
+			select {
+			case work := <-sink:
+				switch {
+				case !flag && len(work) != 4:
+					t.Errorf("flag %v: work %d notification mismatch: have %v, want 4 fields", flag, i, work)
+				case flag && (len(work) != 5 || work[4] != clean):
+					t.Errorf("flag %v: work %d clean flag mismatch: have %v, want %v", flag, i, work, clean)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatalf("flag %v: notification %d timed out", flag, i)
+			}
+			if have := api.GetWorkEpoch(); have != epoch {
+				t.Errorf("flag %v: work %d epoch mismatch: have %d, want %d", flag, i, have, epoch)
+			}
+		}
+		ethash.Close()
+		server.Close()
+	}
+}
+
 // Tests that pushing work packages fast to the miner doesn't cause any data race
 // issues in the notifications.
 func TestRemoteMultiNotify(t *testing.T) {
@@ -202,3 +1225,169 @@ func TestStaleSubmission(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the configured extra-nonce is stamped into the extra-data when the
+// block is assembled, so that the seal hash handed out to remote miners is the
+// one the block producer saw, and solutions carry the extra-nonce.
+func TestExtraNonceSealHash(t *testing.T) {
+	parent := &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(100), GasLimit: 5000}
+	chain := &testChainReader{
+		config:  params.TestChainConfig,
+		headers: map[common.Hash]*types.Header{parent.Hash(): parent},
+	}
+	tests := []struct {
+		extra []byte
+		nonce []byte
+		want  []byte
+	}{
+		{[]byte("pool"), nil, []byte("pool")},
+		{[]byte("pool"), []byte{0x01, 0x02}, []byte("pool\x01\x02")},
+		{bytes.Repeat([]byte{0xff}, 32), []byte{0x03, 0x04}, append(bytes.Repeat([]byte{0xff}, 30), 0x03, 0x04)},
+		{[]byte("pool"), bytes.Repeat([]byte{0x05}, 33), []byte("pool")},
+	}
+	for i, tt := range tests {
+		ethash := New(Config{PowMode: ModeTest, CachesInMem: 1, ExtraNonce: tt.nonce}, nil, true)
+		ethash.SetThreads(-1)
+
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		header := &types.Header{ParentHash: parent.Hash(), Number: big.NewInt(1), Difficulty: big.NewInt(100), GasLimit: 5000, Extra: tt.extra}
+		block, err := ethash.FinalizeAndAssemble(chain, header, statedb, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("test %d: failed to assemble block: %v", i, err)
+		}
+		if !bytes.Equal(block.Extra(), tt.want) {
+			t.Errorf("test %d: extra-data mismatch: have %x, want %x", i, block.Extra(), tt.want)
+		}
+		// Sealing must not alter the seal hash the block producer tracks
+		sealhash := ethash.SealHash(block.Header())
+
+		results := make(chan *types.Block, 1)
+		ethash.Seal(nil, block, results, nil)
+
+		work, err := (&API{ethash}).GetWork()
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve work: %v", i, err)
+		}
+		if work[0] != sealhash.Hex() {
+			t.Errorf("test %d: sealhash mismatch: have %s, want %s", i, work[0], sealhash.Hex())
+		}
+		if !(&API{ethash}).SubmitWork(types.BlockNonce{}, sealhash, common.Hash{}) {
+			t.Errorf("test %d: work submission rejected", i)
+		} else if result := <-results; ethash.SealHash(result.Header()) != sealhash {
+			t.Errorf("test %d: solution sealhash mismatch: have %x, want %x", i, ethash.SealHash(result.Header()), sealhash)
+		}
+		ethash.Close()
+	}
+}
+
+// Tests that malformed work submissions are rejected, both when decoding the RPC
+// parameters and by the engine itself.
+func TestSubmitWorkValidation(t *testing.T) {
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block, 1), nil)
+
+	if err := ethash.SubmitWork(types.BlockNonce{}, common.Hash{}, common.Hash{}); !errors.Is(err, ErrInvalidSubmission) {
+		t.Errorf("empty seal hash error mismatch: have %v, want %v", err, ErrInvalidSubmission)
+	}
+	// Truncated parameters must be rejected before reaching the API
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", &API{ethash}); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	var (
+		nonce    = "0x0000000000000000"
+		sealhash = ethash.SealHash(header).Hex()
+		digest   = common.Hash{}.Hex()
+	)
+	tests := [][3]string{
+		{nonce[:10], sealhash, digest}, // truncated nonce
+		{nonce, sealhash[:34], digest}, // truncated seal hash
+		{nonce, "0x", digest},          // empty seal hash
+		{nonce, sealhash, digest[:10]}, // truncated digest
+	}
+	for i, tt := range tests {
+		var accepted bool
+		if err := client.Call(&accepted, "eth_submitWork", tt[0], tt[1], tt[2]); err == nil {
+			t.Errorf("test %d: malformed submission accepted: %v", i, accepted)
+		}
+	}
+	// A well formed submission must go through
+	var accepted bool
+	if err := client.Call(&accepted, "eth_submitWork", nonce, sealhash, digest); err != nil || !accepted {
+		t.Errorf("valid submission rejected: %v, %v", accepted, err)
+	}
+}
+
+// Tests that sealing and verifying blocks straddling an epoch boundary counts
+// the epoch transition exactly once.
+func TestEpochChange(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	for _, number := range []uint64{epochLength - 1, epochLength, epochLength + 1, epochLength - 2} {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: big.NewInt(1)}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		block, err := ethash.SealBlocking(ctx, nil, types.NewBlockWithHeader(header))
+		cancel()
+		if err != nil {
+			t.Fatalf("block %d: failed to seal: %v", number, err)
+		}
+		if err := ethash.VerifySeal(nil, block.Header()); err != nil {
+			t.Fatalf("block %d: unexpected verification error: %v", number, err)
+		}
+	}
+	if changes := ethash.epochs.Count(); changes != 1 {
+		t.Errorf("epoch change count mismatch: have %d, want 1", changes)
+	}
+}
+
+// Tests that the mining state reflects the configured thread count, and that an
+// idle miner doesn't do any search work.
+func TestMiningState(t *testing.T) {
+	tests := []struct {
+		threads int
+		state   MiningState
+		count   int
+	}{
+		{-1, MiningIdle, 0},
+		{0, MiningAllCores, runtime.NumCPU()},
+		{2, MiningFixed, 2},
+	}
+	for _, tt := range tests {
+		ethash := NewTester(nil, false)
+		ethash.SetThreads(tt.threads)
+
+		if state, count := ethash.MiningState(); state != tt.state || count != tt.count {
+			t.Errorf("threads %d: state mismatch: have %v/%d, want %v/%d", tt.threads, state, count, tt.state, tt.count)
+		}
+		header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+
+		timeout := 5 * time.Second
+		if tt.state == MiningIdle {
+			timeout = 100 * time.Millisecond
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		_, err := ethash.SealBlocking(ctx, nil, types.NewBlockWithHeader(header))
+		cancel()
+
+		switch {
+		case tt.state == MiningIdle && err != context.DeadlineExceeded:
+			t.Errorf("threads %d: idle seal error mismatch: have %v, want %v", tt.threads, err, context.DeadlineExceeded)
+		case tt.state != MiningIdle && err != nil:
+			t.Errorf("threads %d: failed to seal: %v", tt.threads, err)
+		}
+		if progress := ethash.NonceProgress(); len(progress) != tt.count {
+			t.Errorf("threads %d: mining thread count mismatch: have %d, want %d", tt.threads, len(progress), tt.count)
+		}
+		ethash.Close()
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+var (
+	// stateMagic is a mining state header to sanity check an exported state.
+	stateMagic = []uint32{0xbaddcafe, 0x5ea1ab1e}
+
+	// stateVersion is the encoding version of an exported mining state.
+	stateVersion = byte(1)
+)
+
+// minerState is the serialisable mining state exported for hot reloads.
+type minerState struct {
+	Block    *types.Block `rlp:"nil"` // Work package being sealed, nil if none
+	Created  uint64       // Creation time of the work package, in unix nanoseconds
+	SealHash common.Hash  // Seal hash the nonce cursors belong to
+	Cursors  []uint64     // Next nonce to try for each local mining thread
+}
+
+// restoreTask wraps an exported work package to reinstate in the remote sealer.
+type restoreTask struct {
+	block   *types.Block
+	created time.Time
+	done    chan struct{}
+}
+
+// nonceCursors tracks the nonce search progress of the local mining threads
+// working on the same seal hash. The counters, seeds and nonces are accessed
+// atomically.
+type nonceCursors struct {
+	tried    uint64 // Nonces tried by all threads together, published periodically
+	sealhash common.Hash
+	seeds    []uint64 // Nonce each thread started (or restarted) its search from
+	nonces   []uint64 // Next nonce to try for each thread
+}
+
+// NonceProgress is the nonce search progress of a single local mining thread.
+type NonceProgress struct {
+	Thread    int            `json:"thread"`    // Index of the mining thread
+	Seed      hexutil.Uint64 `json:"seed"`      // Nonce the thread started searching from
+	Nonce     hexutil.Uint64 `json:"nonce"`     // Next nonce the thread will try
+	Scanned   hexutil.Uint64 `json:"scanned"`   // Number of nonces tried since the seed
+	Remaining hexutil.Uint64 `json:"remaining"` // Number of nonces left before wrapping around
+}
+
+// trackCursors creates a new set of nonce cursors for the given seal hash and
+// marks them as the ones to export.
+func (ethash *Ethash) trackCursors(sealhash common.Hash, threads int) *nonceCursors {
+	cursors := &nonceCursors{
+		sealhash: sealhash,
+		seeds:    make([]uint64, threads),
+		nonces:   make([]uint64, threads),
+	}
+
+	ethash.lock.Lock()
+	ethash.cursors = cursors
+	ethash.lock.Unlock()
+
+	return cursors
+}
+
+// resumeCursors returns the imported nonce cursors if they belong to the given
+// seal hash. Imported cursors are only ever resumed once.
+func (ethash *Ethash) resumeCursors(sealhash common.Hash) []uint64 {
+	ethash.lock.Lock()
+	defer ethash.lock.Unlock()
+
+	if ethash.resume == nil || ethash.resume.sealhash != sealhash {
+		return nil
+	}
+	nonces := ethash.resume.nonces
+	ethash.resume = nil
+	return nonces
+}
+
+// NonceProgress reports how far into the nonce space each local mining thread has
+// scanned for the work currently being sealed. Threads running out of nonces are
+// restarted from a fresh random seed, resetting their progress.
+//
+// The nonces are only published periodically by the mining threads, so progress
+// advances in steps rather than continuously.
+func (ethash *Ethash) NonceProgress() []NonceProgress {
+	if ethash.shared != nil {
+		return ethash.shared.NonceProgress()
+	}
+	ethash.lock.Lock()
+	cursors := ethash.cursors
+	ethash.lock.Unlock()
+
+	if cursors == nil {
+		return nil
+	}
+	progress := make([]NonceProgress, len(cursors.nonces))
+	for i := range cursors.nonces {
+		seed, nonce := atomic.LoadUint64(&cursors.seeds[i]), atomic.LoadUint64(&cursors.nonces[i])
+		progress[i] = NonceProgress{
+			Thread:    i,
+			Seed:      hexutil.Uint64(seed),
+			Nonce:     hexutil.Uint64(nonce),
+			Scanned:   hexutil.Uint64(nonce - seed),
+			Remaining: hexutil.Uint64(math.MaxUint64 - nonce),
+		}
+	}
+	return progress
+}
+
+// ExportState serialises the current mining state: the work package handed out
+// to remote sealers, its creation time and the nonce cursors of the local mining
+// threads. The result can be fed into ImportState of a fresh instance to resume
+// mining with minimal disruption.
+func (ethash *Ethash) ExportState() ([]byte, error) {
+	if ethash.shared != nil {
+		return ethash.shared.ExportState()
+	}
+	state := new(minerState)
+	if ethash.remote != nil {
+		req := make(chan *minerState, 1)
+		ethash.remote.start()
+		select {
+		case ethash.remote.fetchStateCh <- req:
+		case <-ethash.remote.exitCh:
+			return nil, errEthashStopped
+		}
+		state = <-req
+	}
+	ethash.lock.Lock()
+	if cursors := ethash.cursors; cursors != nil {
+		state.SealHash = cursors.sealhash
+		state.Cursors = make([]uint64, len(cursors.nonces))
+		for i := range cursors.nonces {
+			state.Cursors[i] = atomic.LoadUint64(&cursors.nonces[i])
+		}
+	}
+	ethash.lock.Unlock()
+
+	payload, err := rlp.EncodeToBytes(state)
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, 4*len(stateMagic)+1, 4*len(stateMagic)+1+len(payload))
+	for i, magic := range stateMagic {
+		binary.BigEndian.PutUint32(blob[4*i:], magic)
+	}
+	blob[4*len(stateMagic)] = stateVersion
+
+	return append(blob, payload...), nil
+}
+
+// ImportState restores a mining state previously created by ExportState. The
+// work package is reinstated in the remote sealer (unless newer work is already
+// available, in which case it's only kept for late submissions) and the nonce
+// cursors are resumed by the next local seal of the same work.
+//
+// The restored work is served to remote miners straight away, but there is no
+// result channel to deliver solutions to until the miner calls Seal again, so
+// any solution submitted before that is rejected. Callers should import the
+// state right before resuming sealing.
+func (ethash *Ethash) ImportState(blob []byte) error {
+	if ethash.shared != nil {
+		return ethash.shared.ImportState(blob)
+	}
+	header := 4*len(stateMagic) + 1
+	if len(blob) < header {
+		return ErrInvalidDumpMagic
+	}
+	for i, magic := range stateMagic {
+		if binary.BigEndian.Uint32(blob[4*i:]) != magic {
+			return ErrInvalidDumpMagic
+		}
+	}
+	if version := blob[header-1]; version != stateVersion {
+		return fmt.Errorf("unsupported mining state version %d", version)
+	}
+	state := new(minerState)
+	if err := rlp.DecodeBytes(blob[header:], state); err != nil {
+		return err
+	}
+	if state.Block != nil && ethash.remote != nil {
+		task := &restoreTask{
+			block:   state.Block,
+			created: time.Unix(0, int64(state.Created)),
+			done:    make(chan struct{}),
+		}
+		ethash.remote.start()
+		select {
+		case ethash.remote.restoreCh <- task:
+		case <-ethash.remote.exitCh:
+			return errEthashStopped
+		}
+		<-task.done
+	}
+	if len(state.Cursors) > 0 {
+		ethash.lock.Lock()
+		ethash.resume = &nonceCursors{sealhash: state.SealHash, nonces: state.Cursors}
+		ethash.lock.Unlock()
+	}
+	return nil
+}
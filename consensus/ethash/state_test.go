@@ -0,0 +1,128 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/expanse-org/go-expanse/core/types"
+)
+
+// Tests that the mining state survives an export/import round trip into a fresh
+// engine, and that the imported nonce cursors are resumed by the next seal.
+func TestStateRoundTrip(t *testing.T) {
+	// Seal a block that won't be found and abort it to settle the cursors
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(2)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 128)}
+	block := types.NewBlockWithHeader(header)
+	sealhash := ethash.SealHash(header)
+
+	stop := make(chan struct{})
+	if err := ethash.Seal(nil, block, make(chan *types.Block), stop); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	close(stop)
+	for start := time.Now(); ethash.IsSealing(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 3*time.Second {
+			t.Fatal("engine still sealing after abort")
+		}
+	}
+	work, err := (&API{ethash}).GetWork()
+	if err != nil {
+		t.Fatalf("failed to retrieve work: %v", err)
+	}
+	blob, err := ethash.ExportState()
+	if err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	exported := ethash.cursors
+
+	// Import the state into a fresh engine and check it's reinstated
+	restored := NewTester(nil, false)
+	defer restored.Close()
+	restored.SetThreads(2)
+
+	if err := restored.ImportState(blob); err != nil {
+		t.Fatalf("failed to import state: %v", err)
+	}
+	have, err := (&API{restored}).GetWork()
+	if err != nil {
+		t.Fatalf("failed to retrieve restored work: %v", err)
+	}
+	if have != work {
+		t.Errorf("restored work mismatch: have %v, want %v", have, work)
+	}
+	if restored.resume == nil || restored.resume.sealhash != sealhash {
+		t.Fatalf("nonce cursors not restored: %+v", restored.resume)
+	}
+	if !reflect.DeepEqual(restored.resume.nonces, exported.nonces) {
+		t.Errorf("nonce cursors mismatch: have %v, want %v", restored.resume.nonces, exported.nonces)
+	}
+	// Seal the same block again and ensure the search continues from the cursors
+	stop = make(chan struct{})
+	if err := restored.Seal(nil, block, make(chan *types.Block), stop); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	close(stop)
+	for start := time.Now(); restored.IsSealing(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 3*time.Second {
+			t.Fatal("engine still sealing after abort")
+		}
+	}
+	if restored.resume != nil {
+		t.Errorf("imported nonce cursors not consumed")
+	}
+	for i, nonce := range restored.cursors.nonces {
+		if nonce < exported.nonces[i] {
+			t.Errorf("thread %d: nonce cursor rewound: have %d, want >= %d", i, nonce, exported.nonces[i])
+		}
+	}
+}
+
+// Tests that corrupted or unknown mining states are rejected.
+func TestStateImportErrors(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	blob, err := ethash.ExportState()
+	if err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	if err := ethash.ImportState(blob[:3]); !errors.Is(err, ErrInvalidDumpMagic) {
+		t.Errorf("truncated state: have %v, want %v", err, ErrInvalidDumpMagic)
+	}
+	corrupt := append([]byte{}, blob...)
+	corrupt[0] ^= 0xff
+	if err := ethash.ImportState(corrupt); !errors.Is(err, ErrInvalidDumpMagic) {
+		t.Errorf("corrupt magic: have %v, want %v", err, ErrInvalidDumpMagic)
+	}
+	future := append([]byte{}, blob...)
+	future[4*len(stateMagic)]++
+	if err := ethash.ImportState(future); err == nil {
+		t.Errorf("unknown version accepted")
+	}
+	if err := ethash.ImportState(blob); err != nil {
+		t.Errorf("empty state rejected: %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+const (
+	// verifyNonceSize is the size of a block nonce.
+	verifyNonceSize = len(types.BlockNonce{})
+
+	// verifyRecordSize is the size of a single verified seal entry on disk: the
+	// seal hash, followed by the nonce, the mix digest and the PoW result.
+	verifyRecordSize = common.HashLength + verifyNonceSize + 2*common.HashLength
+
+	// verifyCacheLimit is the number of verified seals remembered, the least
+	// recently used ones being evicted beyond it.
+	verifyCacheLimit = 65536
+
+	// verifyWriteBuffer is the number of verified seals buffered in memory before
+	// being written to disk in one go.
+	verifyWriteBuffer = 64
+)
+
+// verifiedSeal is the solution a seal hash was successfully verified with, and
+// the PoW result it yielded.
+type verifiedSeal struct {
+	nonce  types.BlockNonce
+	digest common.Hash
+	result common.Hash
+}
+
+// verifyCache is an append-only on-disk record of successfully verified seals,
+// allowing the proof-of-work of already verified headers to be skipped across
+// restarts. At most verifyCacheLimit seals are kept: once the file holds twice
+// as many records, it is rewritten with the retained ones only.
+type verifyCache struct {
+	path    string
+	file    *os.File
+	writer  *bufio.Writer
+	records int            // Number of records in the file, including buffered ones
+	seals   *simplelru.LRU // Verified seals, keyed by seal hash
+	lock    sync.Mutex
+}
+
+// openVerifyCache loads the verified seal cache from the given directory, or
+// creates a new one if none exists yet. Corrupted caches are discarded and
+// rebuilt from scratch. Every PoW mode, number of extra keccak rounds and set
+// of memory parameters yields different results, so each has its own cache.
+func openVerifyCache(dir string, mode Mode, rounds int, params MemoryParams, logger log.Logger) (*verifyCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("verified-R%d-P%d-K%d%s", algorithmRevision, mode, rounds, params.tag()))
+
+	blob, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	seals, size, err := parseVerifyCache(blob)
+	if err != nil {
+		logger.Warn("Discarding corrupted seal verification cache", "path", path, "err", err)
+		seals, _ = simplelru.NewLRU(verifyCacheLimit, nil)
+		size = 0
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// Drop any trailing garbage (e.g. a partially written record) and write the
+	// header if the cache is being rebuilt
+	if err := file.Truncate(int64(size)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if size == 0 {
+		if _, err := file.WriteAt(verifyCacheHeader(), 0); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, err
+	}
+	cache := &verifyCache{
+		path:   path,
+		file:   file,
+		writer: bufio.NewWriterSize(file, verifyWriteBuffer*verifyRecordSize),
+		seals:  seals,
+	}
+	if size > 0 {
+		cache.records = (size - 4*len(dumpMagic)) / verifyRecordSize
+	}
+	return cache, nil
+}
+
+// verifyCacheHeader returns the header of a cache file, the big endian dump magic.
+func verifyCacheHeader() []byte {
+	header := make([]byte, 4*len(dumpMagic))
+	for i, magic := range dumpMagic {
+		binary.BigEndian.PutUint32(header[4*i:], magic)
+	}
+	return header
+}
+
+// parseVerifyCache decodes the verified seals contained in a cache file, also
+// returning the size of the valid prefix of the file. Empty files are valid,
+// whereas a missing or mismatching header results in ErrInvalidDumpMagic. If
+// the file holds more than verifyCacheLimit seals, the latest ones are kept.
+func parseVerifyCache(blob []byte) (*simplelru.LRU, int, error) {
+	seals, _ := simplelru.NewLRU(verifyCacheLimit, nil)
+	if len(blob) == 0 {
+		return seals, 0, nil
+	}
+	header := 4 * len(dumpMagic)
+	if len(blob) < header {
+		return nil, 0, ErrInvalidDumpMagic
+	}
+	for i, magic := range dumpMagic {
+		if binary.BigEndian.Uint32(blob[4*i:]) != magic {
+			return nil, 0, ErrInvalidDumpMagic
+		}
+	}
+	size := header
+	for ; size+verifyRecordSize <= len(blob); size += verifyRecordSize {
+		record := blob[size : size+verifyRecordSize]
+
+		var seal verifiedSeal
+		copy(seal.nonce[:], record[common.HashLength:])
+		copy(seal.digest[:], record[common.HashLength+verifyNonceSize:])
+		copy(seal.result[:], record[2*common.HashLength+verifyNonceSize:])
+		seals.Add(common.BytesToHash(record[:common.HashLength]), seal)
+	}
+	return seals, size, nil
+}
+
+// result returns the PoW result of the given solution if it was already verified
+// for the seal hash. The result must still be checked against the target, which
+// may have changed since.
+func (c *verifyCache) result(sealhash common.Hash, nonce types.BlockNonce, digest common.Hash) (common.Hash, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	item, ok := c.seals.Get(sealhash)
+	if !ok {
+		return common.Hash{}, false
+	}
+	seal := item.(verifiedSeal)
+	if seal.nonce != nonce || seal.digest != digest {
+		return common.Hash{}, false
+	}
+	return seal.result, true
+}
+
+// add records a successfully verified solution for the seal hash. The record is
+// buffered, and only written to disk once enough of them accumulated or the
+// cache is closed.
+func (c *verifyCache) add(sealhash common.Hash, nonce types.BlockNonce, digest common.Hash, result common.Hash) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.seals.Contains(sealhash) {
+		return nil
+	}
+	seal := verifiedSeal{nonce: nonce, digest: digest, result: result}
+	c.seals.Add(sealhash, seal)
+	if _, err := c.writer.Write(encodeVerifiedSeal(sealhash, seal)); err != nil {
+		return err
+	}
+	c.records++
+	if c.records >= 2*verifyCacheLimit {
+		return c.compact()
+	}
+	return nil
+}
+
+// encodeVerifiedSeal creates the on-disk record of a verified seal.
+func encodeVerifiedSeal(sealhash common.Hash, seal verifiedSeal) []byte {
+	record := make([]byte, 0, verifyRecordSize)
+	record = append(record, sealhash[:]...)
+	record = append(record, seal.nonce[:]...)
+	record = append(record, seal.digest[:]...)
+	return append(record, seal.result[:]...)
+}
+
+// compact rewrites the cache file with the seals still retained in memory, from
+// the least to the most recently used one, dropping the evicted ones. The new
+// file replaces the old one atomically. The caller must hold the lock.
+func (c *verifyCache) compact() error {
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	file, err := os.Create(c.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriterSize(file, verifyWriteBuffer*verifyRecordSize)
+	writer.Write(verifyCacheHeader())
+	for _, key := range c.seals.Keys() {
+		item, _ := c.seals.Peek(key)
+		writer.Write(encodeVerifiedSeal(key.(common.Hash), item.(verifiedSeal)))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), c.path); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	c.file.Close()
+	c.file, c.writer, c.records = file, bufio.NewWriterSize(file, verifyWriteBuffer*verifyRecordSize), c.seals.Len()
+	return nil
+}
+
+// close writes out any buffered seals and releases the backing cache file.
+func (c *verifyCache) close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.writer.Flush(); err != nil {
+		c.file.Close()
+		return err
+	}
+	return c.file.Close()
+}
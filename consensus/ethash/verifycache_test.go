@@ -0,0 +1,228 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+// Tests that verified seals are persisted and skip the proof-of-work on hits.
+func TestVerifyCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-verifycache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Seal a block and verify it on a separate engine, populating the cache
+	sealer := NewTester(nil, false)
+	defer sealer.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	block, err := sealer.SealBlocking(context.Background(), nil, types.NewBlockWithHeader(header))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	verifier := NewTester(nil, false)
+	if verifier.verified, err = openVerifyCache(dir, ModeTest, 0, MemoryParams{}, log.Root()); err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	if err := verifier.VerifySeal(nil, block.Header()); err != nil {
+		t.Fatalf("failed to verify seal: %v", err)
+	}
+	verifier.Close()
+
+	// Reopen the cache and ensure the seal survived
+	cache, err := openVerifyCache(dir, ModeTest, 0, MemoryParams{}, log.Root())
+	if err != nil {
+		t.Fatalf("failed to reopen cache: %v", err)
+	}
+
+	sealhash := sealer.SealHash(header)
+	if _, ok := cache.result(sealhash, block.Header().Nonce, block.MixDigest()); !ok {
+		t.Fatalf("verified seal not persisted")
+	}
+	if _, ok := cache.result(sealhash, types.EncodeNonce(block.Nonce()+1), block.MixDigest()); ok {
+		t.Errorf("different nonce reported as verified")
+	}
+	// A cache hit must skip the proof-of-work, whereas a miss must check it
+	bogus := &types.Header{Number: big.NewInt(2), Difficulty: big.NewInt(2), Nonce: types.EncodeNonce(1), MixDigest: common.HexToHash("0xcafe")}
+	if err := cache.add(sealer.SealHash(bogus), bogus.Nonce, bogus.MixDigest, common.Hash{}); err != nil {
+		t.Fatalf("failed to add seal: %v", err)
+	}
+	verifier = NewTester(nil, false)
+	verifier.verified = cache
+	defer verifier.Close()
+
+	if err := verifier.VerifySeal(nil, bogus); err != nil {
+		t.Errorf("cache hit verification failed: %v", err)
+	}
+	bogus.Nonce = types.EncodeNonce(2)
+	if err := verifier.VerifySeal(nil, bogus); err != errInvalidMixDigest {
+		t.Errorf("cache miss error mismatch: have %v, want %v", err, errInvalidMixDigest)
+	}
+	// A cache hit whose result exceeds the target must still be rejected
+	bogus = &types.Header{Number: big.NewInt(3), Difficulty: big.NewInt(2), Nonce: types.EncodeNonce(1), MixDigest: common.HexToHash("0xcafe")}
+	if err := cache.add(sealer.SealHash(bogus), bogus.Nonce, bogus.MixDigest, common.BigToHash(new(big.Int).Sub(two256, big.NewInt(1)))); err != nil {
+		t.Fatalf("failed to add seal: %v", err)
+	}
+	if err := verifier.VerifySeal(nil, bogus); err != errInvalidPoW {
+		t.Errorf("cache hit over target error mismatch: have %v, want %v", err, errInvalidPoW)
+	}
+}
+
+// Tests that seals verified under a different PoW mode or number of extra keccak
+// rounds are not reused.
+func TestVerifyCacheSeparation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-verifycache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := openVerifyCache(dir, ModeTest, 0, MemoryParams{}, log.Root())
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	if err := cache.add(common.HexToHash("0x01"), types.BlockNonce{}, common.Hash{}, common.Hash{}); err != nil {
+		t.Fatalf("failed to add seal: %v", err)
+	}
+	cache.close()
+
+	for _, config := range []struct {
+		mode   Mode
+		rounds int
+	}{{ModeNormal, 0}, {ModeTest, 1}} {
+		cache, err := openVerifyCache(dir, config.mode, config.rounds, MemoryParams{}, log.Root())
+		if err != nil {
+			t.Fatalf("mode %d, rounds %d: failed to open cache: %v", config.mode, config.rounds, err)
+		}
+		if _, ok := cache.result(common.HexToHash("0x01"), types.BlockNonce{}, common.Hash{}); ok {
+			t.Errorf("mode %d, rounds %d: seal of different configuration reused", config.mode, config.rounds)
+		}
+		cache.close()
+	}
+}
+
+// Tests that corrupted caches are detected and rebuilt.
+func TestVerifyCacheCorruption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-verifycache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := openVerifyCache(dir, ModeTest, 0, MemoryParams{}, log.Root())
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	path := cache.file.Name()
+	if err := cache.add(common.HexToHash("0x01"), types.BlockNonce{}, common.Hash{}, common.Hash{}); err != nil {
+		t.Fatalf("failed to add seal: %v", err)
+	}
+	cache.close()
+
+	// A partially written record must be dropped, keeping the rest
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, append(blob, 0x01, 0x02), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cache, err = openVerifyCache(dir, ModeTest, 0, MemoryParams{}, log.Root()); err != nil {
+		t.Fatalf("failed to open truncated cache: %v", err)
+	}
+	cache.close()
+	if have, _ := ioutil.ReadFile(path); len(have) != len(blob) {
+		t.Errorf("partial record not dropped: have %d bytes, want %d", len(have), len(blob))
+	}
+	// A corrupted header must be rejected and the cache rebuilt
+	blob[0] ^= 0xff
+	if _, _, err := parseVerifyCache(blob); !errors.Is(err, ErrInvalidDumpMagic) {
+		t.Fatalf("corruption error mismatch: have %v, want %v", err, ErrInvalidDumpMagic)
+	}
+	if err := ioutil.WriteFile(path, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cache, err = openVerifyCache(dir, ModeTest, 0, MemoryParams{}, log.Root()); err != nil {
+		t.Fatalf("failed to rebuild corrupted cache: %v", err)
+	}
+	defer cache.close()
+	if cache.seals.Len() != 0 {
+		t.Errorf("corrupted seals retained: %d", cache.seals.Len())
+	}
+	if have, _ := ioutil.ReadFile(path); len(have) != 4*len(dumpMagic) {
+		t.Errorf("rebuilt cache size mismatch: have %d bytes, want %d", len(have), 4*len(dumpMagic))
+	}
+}
+
+// Tests that the cache is bounded in memory and on disk, retaining the most
+// recently verified seals.
+func TestVerifyCacheLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-verifycache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := openVerifyCache(dir, ModeTest, 0, MemoryParams{}, log.Root())
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	path := cache.file.Name()
+	for i := 0; i < 2*verifyCacheLimit; i++ {
+		if err := cache.add(common.BigToHash(big.NewInt(int64(i))), types.EncodeNonce(uint64(i)), common.Hash{}, common.Hash{}); err != nil {
+			t.Fatalf("failed to add seal %d: %v", i, err)
+		}
+	}
+	if err := cache.close(); err != nil {
+		t.Fatalf("failed to close cache: %v", err)
+	}
+	want := int64(4*len(dumpMagic) + verifyCacheLimit*verifyRecordSize)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat cache: %v", err)
+	}
+	if info.Size() != want {
+		t.Fatalf("compacted cache size mismatch: have %d, want %d", info.Size(), want)
+	}
+	if cache, err = openVerifyCache(dir, ModeTest, 0, MemoryParams{}, log.Root()); err != nil {
+		t.Fatalf("failed to reopen cache: %v", err)
+	}
+	defer cache.close()
+
+	if cache.seals.Len() != verifyCacheLimit {
+		t.Errorf("retained seals mismatch: have %d, want %d", cache.seals.Len(), verifyCacheLimit)
+	}
+	if _, ok := cache.result(common.BigToHash(big.NewInt(0)), types.EncodeNonce(0), common.Hash{}); ok {
+		t.Errorf("oldest seal retained")
+	}
+	last := 2*verifyCacheLimit - 1
+	if _, ok := cache.result(common.BigToHash(big.NewInt(int64(last))), types.EncodeNonce(uint64(last)), common.Hash{}); !ok {
+		t.Errorf("latest seal evicted")
+	}
+}
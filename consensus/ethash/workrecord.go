@@ -0,0 +1,158 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+// workRecordVersion is the version of the work recording format, bumped on any
+// incompatible change.
+const workRecordVersion = 1
+
+// workRecord is a single work package pushed to the remote sealer, as stored in
+// a work recording.
+type workRecord struct {
+	Time   uint64 // Time the work was pushed, in unix nanoseconds
+	Header *types.Header
+}
+
+// workRecorder appends the work packages pushed to the remote sealer to a file,
+// to be replayed later by ReplayWork.
+//
+// A recording starts with the dump magic (big endian) and a version byte, which
+// are followed by the RLP encoded work records in the order they were pushed.
+type workRecorder struct {
+	file *os.File
+}
+
+// newWorkRecorder creates a new work recording at the given path, replacing any
+// previous one.
+func newWorkRecorder(path string) (*workRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4*len(dumpMagic)+1)
+	for i, magic := range dumpMagic {
+		binary.BigEndian.PutUint32(header[4*i:], magic)
+	}
+	header[4*len(dumpMagic)] = workRecordVersion
+
+	if _, err := file.Write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &workRecorder{file: file}, nil
+}
+
+// record appends the given work package to the recording.
+func (r *workRecorder) record(pushed time.Time, header *types.Header) error {
+	return rlp.Encode(r.file, &workRecord{Time: uint64(pushed.UnixNano()), Header: header})
+}
+
+// close finishes the recording.
+func (r *workRecorder) close() error {
+	return r.file.Close()
+}
+
+// readWorkRecording loads all the work packages from a work recording.
+func readWorkRecording(path string) ([]*workRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	header := make([]byte, 4*len(dumpMagic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, ErrInvalidDumpMagic
+	}
+	for i, magic := range dumpMagic {
+		if binary.BigEndian.Uint32(header[4*i:]) != magic {
+			return nil, ErrInvalidDumpMagic
+		}
+	}
+	if version := header[4*len(dumpMagic)]; version != workRecordVersion {
+		return nil, fmt.Errorf("unsupported work recording version %d", version)
+	}
+	var (
+		records []*workRecord
+		stream  = rlp.NewStream(reader, 0)
+	)
+	for {
+		record := new(workRecord)
+		if err := stream.Decode(record); err != nil {
+			if err == io.EOF {
+				return records, nil
+			}
+			return nil, fmt.Errorf("work record %d: %v", len(records), err)
+		}
+		records = append(records, record)
+	}
+}
+
+// ReplayWork pushes the work packages of a recording made via RecordWork to the
+// remote sealer again, keeping the intervals they were originally pushed at, to
+// generate reproducible load for remote miners. It returns once all work has
+// been pushed.
+//
+// Replayed work has no consumer for its results, so solutions submitted for it
+// are verified, but rejected. To keep it from displacing real work, replaying is
+// refused with ErrReplayLiveWork once a block was sealed with a results channel.
+func (ethash *Ethash) ReplayWork(path string) error {
+	if ethash.shared != nil {
+		return ethash.shared.ReplayWork(path)
+	}
+	if ethash.remote == nil {
+		return errors.New("not supported")
+	}
+	records, err := readWorkRecording(path)
+	if err != nil {
+		return err
+	}
+	ethash.remote.start()
+	for i, record := range records {
+		if i > 0 && record.Time > records[i-1].Time {
+			select {
+			case <-time.After(time.Duration(record.Time - records[i-1].Time)):
+			case <-ethash.remote.exitCh:
+				return errEthashStopped
+			}
+		}
+		replay := make(chan error, 1)
+		select {
+		case ethash.remote.workCh <- &sealTask{block: types.NewBlockWithHeader(record.Header), replay: replay}:
+		case <-ethash.remote.exitCh:
+			return errEthashStopped
+		}
+		if err := <-replay; err != nil {
+			return err
+		}
+	}
+	return nil
+}
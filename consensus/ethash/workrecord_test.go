@@ -0,0 +1,118 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/expanse-org/go-expanse/core/types"
+)
+
+// Tests that pushed work packages are recorded, and replayed in order at their
+// original intervals.
+func TestWorkRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-workrecord-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "work.rec")
+
+	// Record two work packages pushed some time apart
+	const gap = 300 * time.Millisecond
+
+	recorder := New(Config{PowMode: ModeTest, CachesInMem: 1, RecordWork: path}, nil, true)
+	recorder.SetThreads(-1)
+	headers := []*types.Header{NewTestHeader(1, 100), NewTestHeader(2, 100)}
+	for i, header := range headers {
+		if i > 0 {
+			time.Sleep(gap)
+		}
+		if err := recorder.Seal(nil, types.NewBlockWithHeader(header), nil, nil); err != nil {
+			t.Fatalf("failed to seal block %d: %v", i, err)
+		}
+	}
+	recorder.Close()
+
+	// Replay them into a fresh engine, checking the work it hands out
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+
+	start, errc := time.Now(), make(chan error, 1)
+	go func() { errc <- ethash.ReplayWork(path) }()
+
+	api := &API{ethash}
+	for i, header := range headers {
+		want := ethash.SealHash(header).Hex()
+		for deadline := time.Now().Add(3 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+			if work, err := api.GetWork(); err == nil && work[0] == want {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("work %d not replayed", i)
+			}
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("failed to replay work: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < gap {
+		t.Errorf("replay too fast: have %v, want about %v", elapsed, gap)
+	}
+	// Anything but a work recording must be rejected
+	if err := ioutil.WriteFile(path, []byte("not a recording"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ethash.ReplayWork(path); !errors.Is(err, ErrInvalidDumpMagic) {
+		t.Errorf("invalid recording error mismatch: have %v, want %v", err, ErrInvalidDumpMagic)
+	}
+}
+
+// Tests that work isn't replayed into an engine sealing real work.
+func TestWorkReplayLiveWork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-workrecord-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "work.rec")
+
+	recorder := New(Config{PowMode: ModeTest, CachesInMem: 1, RecordWork: path}, nil, true)
+	recorder.SetThreads(-1)
+	if err := recorder.Seal(nil, types.NewBlockWithHeader(NewTestHeader(1, 100)), nil, nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	recorder.Close()
+
+	ethash := NewTester(nil, true)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	header := NewTestHeader(5, 100)
+	ethash.Seal(nil, types.NewBlockWithHeader(header), make(chan *types.Block, 1), nil)
+	if err := ethash.ReplayWork(path); !errors.Is(err, ErrReplayLiveWork) {
+		t.Fatalf("replay error mismatch: have %v, want %v", err, ErrReplayLiveWork)
+	}
+	if work, err := (&API{ethash}).GetWork(); err != nil || work[0] != ethash.SealHash(header).Hex() {
+		t.Errorf("real work replaced: have %v (%v), want %s", work[0], err, ethash.SealHash(header).Hex())
+	}
+}
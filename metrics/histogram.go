@@ -34,6 +34,14 @@ func NewHistogram(s Sample) Histogram {
 	return &StandardHistogram{sample: s}
 }
 
+// NewHistogramForced constructs a new StandardHistogram from a Sample and returns
+// it no matter if the global switch is enabled or not. The sample should be
+// constructed irrespective of the global switch too, e.g. with
+// NewExpDecaySampleForced.
+func NewHistogramForced(s Sample) Histogram {
+	return &StandardHistogram{sample: s}
+}
+
 // NewRegisteredHistogram constructs and registers a new StandardHistogram from
 // a Sample.
 func NewRegisteredHistogram(name string, r Registry, s Sample) Histogram {
@@ -49,6 +49,12 @@ func NewExpDecaySample(reservoirSize int, alpha float64) Sample {
 	if !Enabled {
 		return NilSample{}
 	}
+	return NewExpDecaySampleForced(reservoirSize, alpha)
+}
+
+// NewExpDecaySampleForced constructs a new exponentially-decaying sample with the
+// given reservoir size and alpha, no matter if the global switch is enabled or not.
+func NewExpDecaySampleForced(reservoirSize int, alpha float64) Sample {
 	s := &ExpDecaySample{
 		alpha:         alpha,
 		reservoirSize: reservoirSize,